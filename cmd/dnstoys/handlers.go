@@ -6,6 +6,7 @@ import (
 	"log"
 	"net"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/miekg/dns"
@@ -18,19 +19,27 @@ type Service interface {
 	Dump() ([]byte, error)
 }
 
+// HealthReporter is implemented by Services that report their health,
+// such as the freshness of their data, on the `health` query.
+type HealthReporter interface {
+	Health() []string
+}
+
 type handlers struct {
 	services map[string]Service
 	domain   string
 	help     []dns.RR
 }
 
-var reClean = regexp.MustCompile("[^a-zA-Z0-9/\\-\\.:,]")
+var reClean = regexp.MustCompile("[^a-zA-Z0-9/\\-\\.:,@+?]")
 
 const (
 	// TTL is set to 60 seconds (1 Minute).
 	IP_TTL = 60
 	// TTL is set to 1 year (60*60*24*365 = 3,15,36,000).
 	PI_TTL = 31536000
+	// TTL is set to 1 second as health should always be fresh.
+	HEALTH_TTL = 1
 )
 
 // register registers a Service for a given query suffix on the DNS server.
@@ -168,6 +177,46 @@ func (h *handlers) handlePi(w dns.ResponseWriter, r *dns.Msg) {
 	w.WriteMsg(m)
 }
 
+// handleHealth returns the health reports of the services that implement
+// HealthReporter, one TXT record per service.
+func (h *handlers) handleHealth(w dns.ResponseWriter, r *dns.Msg) {
+	m := &dns.Msg{}
+	m.SetReply(r)
+	m.Compress = false
+
+	names := make([]string, 0, len(h.services))
+	for name := range h.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		hr, ok := h.services[name].(HealthReporter)
+		if !ok {
+			continue
+		}
+
+		rrstr := fmt.Sprintf("health. %d TXT \"%s\"", HEALTH_TTL, name)
+		for _, l := range hr.Health() {
+			rrstr += fmt.Sprintf(" \"%s\"", l)
+		}
+
+		rr, err := dns.NewRR(rrstr)
+		if err != nil {
+			lo.Printf("error preparing health response: %v", err)
+			continue
+		}
+		m.Answer = append(m.Answer, rr)
+	}
+
+	if len(m.Answer) == 0 {
+		rr, _ := dns.NewRR(fmt.Sprintf("health. %d TXT \"ok\"", HEALTH_TTL))
+		m.Answer = append(m.Answer, rr)
+	}
+
+	w.WriteMsg(m)
+}
+
 func (h *handlers) handleHelp(w dns.ResponseWriter, r *dns.Msg) {
 	m := &dns.Msg{}
 	m.SetReply(r)
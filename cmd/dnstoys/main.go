@@ -13,6 +13,7 @@ import (
 	"github.com/knadh/dns.toys/internal/ifsc"
 	"github.com/knadh/dns.toys/internal/services/aerial"
 	"github.com/knadh/dns.toys/internal/services/aqi"
+	"github.com/knadh/dns.toys/internal/services/asn"
 	"github.com/knadh/dns.toys/internal/services/base"
 	"github.com/knadh/dns.toys/internal/services/cidr"
 	"github.com/knadh/dns.toys/internal/services/coin"
@@ -22,11 +23,15 @@ import (
 	"github.com/knadh/dns.toys/internal/services/epoch"
 	"github.com/knadh/dns.toys/internal/services/excuse"
 	"github.com/knadh/dns.toys/internal/services/fx"
+	"github.com/knadh/dns.toys/internal/services/geocode"
+	"github.com/knadh/dns.toys/internal/services/ipinfo"
+	"github.com/knadh/dns.toys/internal/services/moon"
 	"github.com/knadh/dns.toys/internal/services/nanoid"
 	"github.com/knadh/dns.toys/internal/services/num2words"
 	"github.com/knadh/dns.toys/internal/services/random"
 	"github.com/knadh/dns.toys/internal/services/sky"
 	"github.com/knadh/dns.toys/internal/services/sudoku"
+	"github.com/knadh/dns.toys/internal/services/sun"
 	"github.com/knadh/dns.toys/internal/services/timezones"
 	"github.com/knadh/dns.toys/internal/services/units"
 	"github.com/knadh/dns.toys/internal/services/uuid"
@@ -162,11 +167,16 @@ func main() {
 	)
 
 	// Timezone service.
-	if ko.Bool("timezones.enabled") || ko.Bool("weather.enabled") || ko.Bool("aqi.enabled") {
+	if ko.Bool("timezones.enabled") || ko.Bool("weather.enabled") || ko.Bool("aqi.enabled") || ko.Bool("geo.enabled") ||
+		ko.Bool("sun.enabled") || ko.Bool("moon.enabled") || ko.Bool("sky.enabled") {
 		fPath := ko.MustString("timezones.geo_filepath")
 		lo.Printf("reading geo locations from %s", fPath)
 
-		g, err := geo.New(fPath)
+		g, err := geo.New(geo.Opt{
+			FilePath:   fPath,
+			Admin1Path: ko.String("timezones.admin1_filepath"),
+			IndexPath:  ko.String("timezones.index_filepath"),
+		})
 		if err != nil {
 			lo.Fatalf("error loading geo locations: %v", err)
 		}
@@ -183,11 +193,43 @@ func main() {
 		help = append(help, []string{"get time for a city", "dig mumbai.time @%s"})
 	}
 
+	// Reverse geocoding, nearest cities to a lat,lng.
+	if ko.Bool("geo.enabled") {
+		g := geocode.New(geocode.Opt{
+			MaxResults: ko.Int("geo.max_results"),
+		}, ge)
+		h.register("geo", g, mux)
+
+		help = append(help, []string{"get the nearest cities to a lat,lng", "dig 12.97,77.59.geo @%s"})
+	}
+
 	// FX currency conversion.
 	if ko.Bool("fx.enabled") {
-		f := fx.New(fx.Opt{
+		var provs, cryptoProvs []fx.ProviderOpt
+		if err := ko.Unmarshal("fx.providers", &provs); err != nil {
+			lo.Fatalf("error reading fx providers config: %v", err)
+		}
+		if err := ko.Unmarshal("fx.crypto_providers", &cryptoProvs); err != nil {
+			lo.Fatalf("error reading fx crypto providers config: %v", err)
+		}
+
+		f, err := fx.New(fx.Opt{
 			RefreshInterval: ko.MustDuration("fx.refresh_interval"),
+			HistoryFile:     ko.String("fx.history_file"),
+			Providers:       provs,
+			ReqTimeout:      ko.Duration("fx.request_timeout"),
+			MaxDeviation:    ko.Float64("fx.max_deviation"),
+
+			CryptoProviders:       cryptoProvs,
+			CryptoRefreshInterval: ko.Duration("fx.crypto_refresh_interval"),
+			CryptoMaxAge:          ko.Duration("fx.crypto_max_age"),
+
+			StaleAfter: ko.Duration("fx.stale_after"),
+			MaxAge:     ko.Duration("fx.max_age"),
 		})
+		if err != nil {
+			lo.Fatalf("error initializing fx service: %v", err)
+		}
 
 		// Load snapshot?
 		if b := loadSnapshot("fx"); b != nil {
@@ -239,6 +281,9 @@ func main() {
 		h.register("unit", u, mux)
 
 		help = append(help, []string{"convert between units.", "dig 42km-cm.unit @%s"})
+		help = append(help, []string{"list units convertible from a unit.", "dig km.unit @%s"})
+		help = append(help, []string{"value in the most readable unit.", "dig 123456789m.best.unit @%s"})
+		help = append(help, []string{"search units by name (? matches anything).", "dig kilo?.unit @%s"})
 	}
 
 	// Numbers to words.
@@ -255,6 +300,35 @@ func main() {
 		h.register("cidr", n, mux)
 
 		help = append(help, []string{"convert cidr to ip range.", "dig 10.100.0.0/24.cidr @%s"})
+		help = append(help, []string{"split a cidr into subnets.", "dig 10.0.0.0/16.split24.cidr @%s"})
+		help = append(help, []string{"check if an ip is in a cidr.", "dig 10.0.0.5-10.0.0.0/24.cidr @%s"})
+		help = append(help, []string{"convert ip range to cidrs.", "dig 10.0.0.0-10.0.3.255.cidr @%s"})
+		help = append(help, []string{"aggregate cidrs and find overlaps.", "dig 10.0.0.0/24,10.0.1.0/24.cidr @%s"})
+	}
+
+	// IP info.
+	if ko.Bool("ipinfo.enabled") {
+		n := ipinfo.New()
+		h.register("ipinfo", n, mux)
+
+		help = append(help, []string{"classify an ip and get its int, hex, binary and reverse dns forms.", "dig 100.64.0.1.ipinfo @%s"})
+	}
+
+	// ASN.
+	if ko.Bool("asn.enabled") {
+		a, err := asn.New(asn.Opt{
+			File:            ko.MustString("asn.filepath"),
+			URL:             ko.String("asn.url"),
+			RefreshInterval: ko.Duration("asn.refresh_interval"),
+			ReqTimeout:      ko.Duration("asn.request_timeout"),
+		})
+		if err != nil {
+			lo.Fatalf("error initializing asn service: %v", err)
+		}
+		h.register("asn", a, mux)
+
+		help = append(help, []string{"get the origin AS and prefix of an ip.", "dig 8.8.8.8.asn @%s"})
+		help = append(help, []string{"get the details of an AS.", "dig AS13335.asn @%s"})
 	}
 
 	// PI.
@@ -320,7 +394,7 @@ func main() {
 
 	// Aerial Distance between Lat,Lng
 	if ko.Bool("aerial.enabled") {
-		a := aerial.New()
+		a := aerial.New(ge)
 		h.register("aerial", a, mux)
 
 		help = append(help, []string{"get aerial distance between lat lng pair", "dig A12.9352,77.6245/12.9698,77.7500.aerial @%s"})
@@ -378,6 +452,7 @@ func main() {
 			CacheTTL:         ko.MustDuration("aqi.cache_ttl"),
 			ReqTimeout:       ko.MustDuration("aqi.request_timeout"),
 			UserAgent:        ko.MustString("server.domain"),
+			Standard:         ko.String("aqi.standard"),
 		}, ge)
 		h.register("aqi", a, mux)
 
@@ -402,21 +477,44 @@ func main() {
 
 	// Digipin service
 	if ko.Bool("digipin.enabled") {
-		d := digipin.New()
+		d := digipin.New(ge)
 		h.register("digipin", d, mux)
 
 		help = append(help, []string{"encode lat,lng to digipin or decode digipin to lat,lng", "dig 28.6139,77.2090.digipin @%s"})
 	}
 
-	// ISS / sky position tacker.
+	// Satellite, sun, moon, and planet position tracker.
 	if ko.Bool("sky.enabled") {
-		d := sky.New(sky.Opt{
-			CacheTTL: ko.MustDuration("sky.cache_ttl"),
-			APIKey:   ko.MustString("sky.n2yo_api_key"),
-		})
+		d, err := sky.New(sky.Opt{
+			TLEFile:         ko.MustString("sky.tle_filepath"),
+			TLEURL:          ko.String("sky.tle_url"),
+			RefreshInterval: ko.Duration("sky.refresh_interval"),
+			ReqTimeout:      ko.Duration("sky.request_timeout"),
+		}, ge)
+		if err != nil {
+			lo.Fatalf("error initializing sky service: %v", err)
+		}
 		h.register("sky", d, mux)
 
-		help = append(help, []string{"get the position of ISS", "dig iss.sky @%s"})
+		help = append(help, []string{"get the position of a satellite by name or NORAD ID", "dig iss.sky @%s"})
+		help = append(help, []string{"get the next passes of a satellite over a city", "dig iss.berlin.sky @%s"})
+		help = append(help, []string{"get the position of the sun, moon, or a planet, and its rise and set for a city", "dig mars.berlin.sky @%s"})
+	}
+
+	// Sunrise, sunset, and twilight.
+	if ko.Bool("sun.enabled") {
+		s := sun.New(ge)
+		h.register("sun", s, mux)
+
+		help = append(help, []string{"get sunrise, sunset, and twilight times for a city", "dig berlin.sun @%s"})
+	}
+
+	// Lunar phase, moonrise, and moonset.
+	if ko.Bool("moon.enabled") {
+		m := moon.New(ge)
+		h.register("moon", m, mux)
+
+		help = append(help, []string{"get the moon's phase, and moonrise and moonset for a city", "dig berlin.moon @%s"})
 	}
 
 	// Prepare the static help response for the `help` query.
@@ -429,6 +527,11 @@ func main() {
 		h.help = append(h.help, r)
 	}
 
+	// Health of the services, eg: freshness of fx rates.
+	if ko.Bool("health.enabled") {
+		mux.HandleFunc("health.", h.handleHealth)
+	}
+
 	mux.HandleFunc("help.", h.handleHelp)
 	mux.HandleFunc(".", (h.handleDefault))
 
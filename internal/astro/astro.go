@@ -0,0 +1,74 @@
+// Package astro computes the positions of celestial bodies and the times
+// of astronomical events locally, without any external APIs.
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	deg = math.Pi / 180
+
+	// jd2000 is the Julian day of the J2000.0 epoch (2000-01-01 12:00 TT).
+	jd2000 = 2451545.0
+
+	// unixEpochJD is the Julian day of the Unix epoch.
+	unixEpochJD = 2440587.5
+)
+
+// JulianDay returns the Julian day for a time.
+func JulianDay(t time.Time) float64 {
+	return unixEpochJD + float64(t.UnixNano())/float64(24*time.Hour)
+}
+
+// FromJulianDay returns the time for a Julian day.
+func FromJulianDay(jd float64) time.Time {
+	return time.Unix(0, int64((jd-unixEpochJD)*float64(24*time.Hour))).UTC()
+}
+
+// Equatorial converts ecliptic longitude and latitude to right ascension
+// and declination for the obliquity of the ecliptic eps, all in degrees.
+func Equatorial(lambda, beta, eps float64) (ra, dec float64) {
+	ra = norm360(atan2(sin(lambda)*cos(eps)-tan(beta)*sin(eps), cos(lambda)))
+	dec = asin(sin(beta)*cos(eps) + cos(beta)*sin(eps)*sin(lambda))
+	return ra, dec
+}
+
+// SiderealTime returns the local mean sidereal time in degrees for a Julian
+// day at a longitude (east positive).
+func SiderealTime(jd, lon float64) float64 {
+	t := centuries(jd)
+	return norm360(280.46061837 + 360.98564736629*(jd-jd2000) + t*t*(0.000387933-t/38710000) + lon)
+}
+
+// Horizontal converts right ascension and declination to altitude and
+// azimuth (from north, eastwards) at lat, lon for a Julian day, all in degrees.
+func Horizontal(ra, dec, lat, lon, jd float64) (alt, az float64) {
+	h := SiderealTime(jd, lon) - ra
+
+	alt = asin(sin(lat)*sin(dec) + cos(lat)*cos(dec)*cos(h))
+	az = norm360(atan2(sin(h), cos(h)*sin(lat)-tan(dec)*cos(lat)) + 180)
+	return alt, az
+}
+
+// centuries returns the Julian centuries since J2000.0.
+func centuries(jd float64) float64 {
+	return (jd - jd2000) / 36525
+}
+
+// norm360 normalizes an angle in degrees to [0, 360).
+func norm360(a float64) float64 {
+	a = math.Mod(a, 360)
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
+
+func sin(d float64) float64 { return math.Sin(d * deg) }
+func cos(d float64) float64 { return math.Cos(d * deg) }
+func tan(d float64) float64 { return math.Tan(d * deg) }
+
+func asin(x float64) float64     { return math.Asin(x) / deg }
+func atan2(y, x float64) float64 { return math.Atan2(y, x) / deg }
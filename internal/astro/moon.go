@@ -0,0 +1,260 @@
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+// SynodicMonth is the mean length of a lunation in days.
+const SynodicMonth = 29.530588853
+
+// moonAltitude is the altitude in degrees of the moon's centre at rise/set,
+// accounting for the moon's mean parallax, apparent radius, and atmospheric
+// refraction.
+const moonAltitude = 0.125
+
+// moonTerm is a periodic term of the lunar theory with the multiples of
+// the arguments D, M, M', F and the sine (longitude, latitude) or cosine
+// (distance) coefficient.
+type moonTerm struct {
+	d, m, mp, f float64
+	a           float64
+}
+
+// The largest periodic terms of the moon's longitude (1e-6 degrees) and
+// distance (1e-3 km) from Meeus, Astronomical Algorithms, table 47.A. The
+// truncated series is accurate to a few arcminutes.
+var moonLon = []struct {
+	moonTerm
+	r float64
+}{
+	{moonTerm{0, 0, 1, 0, 6288774}, -20905355},
+	{moonTerm{2, 0, -1, 0, 1274027}, -3699111},
+	{moonTerm{2, 0, 0, 0, 658314}, -2955968},
+	{moonTerm{0, 0, 2, 0, 213618}, -569925},
+	{moonTerm{0, 1, 0, 0, -185116}, 48888},
+	{moonTerm{0, 0, 0, 2, -114332}, -3149},
+	{moonTerm{2, 0, -2, 0, 58793}, 246158},
+	{moonTerm{2, -1, -1, 0, 57066}, -152138},
+	{moonTerm{2, 0, 1, 0, 53322}, -170733},
+	{moonTerm{2, -1, 0, 0, 45758}, -204586},
+	{moonTerm{0, 1, -1, 0, -40923}, -129620},
+	{moonTerm{1, 0, 0, 0, -34720}, 108743},
+	{moonTerm{0, 1, 1, 0, -30383}, 104755},
+	{moonTerm{2, 0, 0, -2, 15327}, 10321},
+	{moonTerm{0, 0, 1, 2, -12528}, 0},
+	{moonTerm{0, 0, 1, -2, 10980}, 79661},
+	{moonTerm{4, 0, -1, 0, 10675}, -34782},
+	{moonTerm{0, 0, 3, 0, 10034}, -23210},
+	{moonTerm{4, 0, -2, 0, 8548}, -21636},
+	{moonTerm{2, 1, -1, 0, -7888}, 24208},
+	{moonTerm{2, 1, 0, 0, -6766}, 30824},
+	{moonTerm{1, 0, -1, 0, -5163}, -8379},
+	{moonTerm{1, 1, 0, 0, 4987}, -16675},
+	{moonTerm{2, -1, 1, 0, 4036}, -12831},
+	{moonTerm{2, 0, 2, 0, 3994}, -10445},
+	{moonTerm{4, 0, 0, 0, 3861}, -11650},
+	{moonTerm{2, 0, -3, 0, 3665}, 14403},
+	{moonTerm{0, 1, -2, 0, -2689}, -7003},
+	{moonTerm{2, 0, -1, 2, -2602}, 0},
+	{moonTerm{2, -1, -2, 0, 2390}, 10056},
+	{moonTerm{1, 0, 1, 0, -2348}, 6322},
+	{moonTerm{2, -2, 0, 0, 2236}, -9884},
+}
+
+// The largest periodic terms of the moon's latitude (1e-6 degrees) from
+// Meeus, table 47.B.
+var moonLat = []moonTerm{
+	{0, 0, 0, 1, 5128122},
+	{0, 0, 1, 1, 280602},
+	{0, 0, 1, -1, 277693},
+	{2, 0, 0, -1, 173237},
+	{2, 0, -1, 1, 55413},
+	{2, 0, -1, -1, 46271},
+	{2, 0, 0, 1, 32573},
+	{0, 0, 2, 1, 17198},
+	{2, 0, 1, -1, 9266},
+	{0, 0, 2, -1, 8822},
+	{2, -1, 0, -1, 8216},
+	{2, 0, -2, -1, 4324},
+	{2, 0, 1, 1, 4200},
+	{2, 1, 0, -1, -3359},
+	{2, -1, -1, 1, 2463},
+	{2, -1, 0, 1, 2211},
+	{2, -1, -1, -1, 2065},
+	{0, 1, -1, -1, -1870},
+	{4, 0, -1, -1, 1828},
+	{0, 1, 0, 1, -1794},
+}
+
+// MoonEcliptic returns the moon's apparent geocentric ecliptic longitude
+// and latitude in degrees and its distance in km for a Julian day.
+func MoonEcliptic(jd float64) (lambda, beta, dist float64) {
+	var (
+		t = centuries(jd)
+
+		// Mean longitude, elongation, the sun's and moon's mean anomalies,
+		// and the argument of latitude.
+		lp = 218.3164477 + t*(481267.88123421+t*(-0.0015786+t*(1.0/538841-t/65194000)))
+		d  = 297.8501921 + t*(445267.1114034+t*(-0.0018819+t*(1.0/545868-t/113065000)))
+		m  = 357.5291092 + t*(35999.0502909+t*(-0.0001536+t/24490000))
+		mp = 134.9633964 + t*(477198.8675055+t*(0.0087414+t*(1.0/69699-t/14712000)))
+		f  = 93.2720950 + t*(483202.0175233+t*(-0.0036539+t*(-1.0/3526000+t/863310000)))
+
+		// Correction for the decreasing eccentricity of earth's orbit.
+		e = 1 - t*(0.002516+0.0000074*t)
+
+		a1 = 119.75 + 131.849*t
+		a2 = 53.09 + 479264.290*t
+		a3 = 313.45 + 481266.484*t
+
+		sl, sr, sb float64
+	)
+
+	arg := func(p moonTerm) (float64, float64) {
+		return p.d*d + p.m*m + p.mp*mp + p.f*f, math.Pow(e, math.Abs(p.m))
+	}
+
+	for _, p := range moonLon {
+		a, c := arg(p.moonTerm)
+		sl += c * p.a * sin(a)
+		sr += c * p.r * cos(a)
+	}
+	for _, p := range moonLat {
+		a, c := arg(p)
+		sb += c * p.a * sin(a)
+	}
+
+	// Additive terms for the action of Venus, Jupiter, and earth's flattening.
+	sl += 3958*sin(a1) + 1962*sin(lp-f) + 318*sin(a2)
+	sb += -2235*sin(lp) + 382*sin(a3) + 175*sin(a1-f) + 175*sin(a1+f) + 127*sin(lp-mp) - 115*sin(lp+mp)
+
+	// Nutation in longitude.
+	omega := 125.04 - 1934.136*t
+
+	lambda = norm360(lp + sl/1e6 - 0.00478*sin(omega))
+	beta = sb / 1e6
+	dist = 385000.56 + sr/1000
+
+	return lambda, beta, dist
+}
+
+// MoonPosition returns the moon's apparent geocentric right ascension and
+// declination in degrees for a Julian day.
+func MoonPosition(jd float64) (ra, dec float64) {
+	var (
+		lambda, beta, _ = MoonEcliptic(jd)
+		_, eps, _, _, _ = sunEcliptic(centuries(jd))
+	)
+
+	return Equatorial(lambda, beta, eps)
+}
+
+// MoonElongation returns the moon's phase as the difference between the
+// apparent ecliptic longitudes of the moon and the sun in degrees, [0, 360).
+// It's 0 at the new moon, 90 at the first quarter, 180 at the full moon,
+// and 270 at the last quarter.
+func MoonElongation(jd float64) float64 {
+	lambda, _, _ := MoonEcliptic(jd)
+	return norm360(lambda - SunLongitude(jd))
+}
+
+// MoonIllumination returns the illuminated fraction of the moon's disk,
+// [0, 1], for a Julian day.
+func MoonIllumination(jd float64) float64 {
+	var (
+		lambda, beta, dist = MoonEcliptic(jd)
+		sun                = SunLongitude(jd)
+
+		// Geocentric elongation of the moon from the sun and the phase
+		// angle, taking the sun to be at 1 AU.
+		psi = math.Acos(cos(beta)*cos(lambda-sun)) / deg
+		i   = atan2(KMPerAU*sin(psi), dist-KMPerAU*cos(psi))
+	)
+
+	return (1 + cos(i)) / 2
+}
+
+// MoonPhase returns the time of the next (or with backward, the previous)
+// moon phase at the given elongation in degrees (0 for the new moon, 180 for
+// the full moon) from t.
+func MoonPhase(t time.Time, elongation float64, backward bool) time.Time {
+	var (
+		jd = JulianDay(t)
+
+		// Mean motion of the elongation in degrees a day.
+		rate = 360 / SynodicMonth
+
+		// Degrees to go to the target.
+		diff = norm360(elongation - MoonElongation(jd))
+	)
+	if backward {
+		diff -= 360
+	}
+
+	// Refine the estimate with the actual elongation, which varies around
+	// its mean motion by several degrees.
+	jd += diff / rate
+	for i := 0; i < 6; i++ {
+		d := math.Remainder(elongation-MoonElongation(jd), 360)
+		jd += d / rate
+		if math.Abs(d) < 1e-6 {
+			break
+		}
+	}
+
+	return FromJulianDay(jd)
+}
+
+// MoonRiseSet returns the moonrise and moonset times at lat, lon within the
+// day starting at the given time. rise or set is zero if the moon doesn't
+// rise or set within the day, which happens about once a month.
+func MoonRiseSet(day time.Time, lat, lon float64) (rise, set time.Time) {
+	return riseSet(day, lat, lon, moonAltitude, MoonPosition)
+}
+
+// riseSet returns the times within the day starting at the given time when
+// a body whose position is given by pos crosses the altitude h0 (degrees)
+// rising and setting at lat, lon.
+func riseSet(day time.Time, lat, lon, h0 float64, pos func(jd float64) (ra, dec float64)) (rise, set time.Time) {
+	alt := func(jd float64) float64 {
+		ra, dec := pos(jd)
+		a, _ := Horizontal(ra, dec, lat, lon, jd)
+		return a - h0
+	}
+
+	// Scan the day in steps short enough for the body to not rise and set
+	// between them, and bisect the crossings of the horizon.
+	const step = 1.0 / 48
+
+	var (
+		start = JulianDay(day)
+		end   = JulianDay(day.AddDate(0, 0, 1))
+		prev  = alt(start)
+	)
+	for jd := start; jd < end && (rise.IsZero() || set.IsZero()); jd += step {
+		next := math.Min(jd+step, end)
+		cur := alt(next)
+		if (prev < 0) != (cur < 0) {
+			lo, hi := jd, next
+			for hi-lo > 1.0/86400 {
+				mid := (lo + hi) / 2
+				if (alt(mid) < 0) == (prev < 0) {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+
+			t := FromJulianDay((lo + hi) / 2)
+			if prev < 0 && rise.IsZero() {
+				rise = t
+			} else if prev >= 0 && set.IsZero() {
+				set = t
+			}
+		}
+		prev = cur
+	}
+
+	return rise, set
+}
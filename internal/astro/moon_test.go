@@ -0,0 +1,61 @@
+package astro
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMoonEcliptic(t *testing.T) {
+	// Meeus, Astronomical Algorithms, example 47.a.
+	lambda, beta, dist := MoonEcliptic(2448724.5)
+	if math.Abs(lambda-133.167265) > 0.05 || math.Abs(beta+3.229126) > 0.05 || math.Abs(dist-368409.7) > 50 {
+		t.Errorf("unexpected position: %f, %f, %f", lambda, beta, dist)
+	}
+}
+
+func TestMoonPhase(t *testing.T) {
+	from := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	// Published new and full moon times, to within a few minutes.
+	tests := []struct {
+		elongation float64
+		backward   bool
+		want       time.Time
+	}{
+		{0, false, time.Date(2024, 4, 8, 18, 21, 0, 0, time.UTC)},
+		{180, false, time.Date(2024, 4, 23, 23, 49, 0, 0, time.UTC)},
+		{180, true, time.Date(2024, 3, 25, 7, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tests {
+		got := MoonPhase(from, tc.elongation, tc.backward)
+		if d := got.Sub(tc.want); d < -5*time.Minute || d > 5*time.Minute {
+			t.Errorf("%v: want %v got %v", tc.elongation, tc.want, got)
+		}
+	}
+
+	if i := MoonIllumination(JulianDay(tests[0].want)); i > 0.01 {
+		t.Errorf("new moon: unexpected illumination %f", i)
+	}
+	if i := MoonIllumination(JulianDay(tests[1].want)); i < 0.99 {
+		t.Errorf("full moon: unexpected illumination %f", i)
+	}
+}
+
+func TestMoonRiseSet(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	day := time.Date(2024, 4, 23, 0, 0, 0, 0, berlin)
+
+	rise, set := MoonRiseSet(day, 52.52437, 13.41053)
+	if rise.IsZero() || set.IsZero() {
+		t.Fatalf("expected moonrise and moonset")
+	}
+
+	// On the full moon, the moon rises around sunset and sets around sunrise.
+	if h := rise.In(berlin).Hour(); h < 18 || h > 22 {
+		t.Errorf("unexpected moonrise: %v", rise.In(berlin))
+	}
+	if h := set.In(berlin).Hour(); h < 4 || h > 8 {
+		t.Errorf("unexpected moonset: %v", set.In(berlin))
+	}
+}
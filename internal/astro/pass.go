@@ -0,0 +1,183 @@
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+// Pass is a satellite's pass over an observer, from when it rises above
+// the horizon to when it sets.
+type Pass struct {
+	Rise, Max, Set time.Time
+
+	// Elevation and azimuth in degrees at the highest point.
+	MaxElevation float64
+	MaxAzimuth   float64
+
+	// Sunlit is true if the satellite is lit by the sun at the highest point.
+	Sunlit bool
+}
+
+// LookAngles returns the azimuth (from north, eastwards) and elevation in
+// degrees and the range in km of a TEME position from an observer at lat,
+// lon (degrees) on the WGS-84 ellipsoid at a time.
+func LookAngles(pos [3]float64, t time.Time, lat, lon float64) (az, el, rng float64) {
+	const (
+		a  = 6378.137
+		f  = 1 / 298.257223563
+		e2 = f * (2 - f)
+	)
+
+	// The observer's position in the TEME frame, rotated by the local
+	// sidereal time.
+	var (
+		theta = SiderealTime(JulianDay(t), lon)
+		c     = a / math.Sqrt(1-e2*sin(lat)*sin(lat))
+		obs   = [3]float64{c * cos(lat) * cos(theta), c * cos(lat) * sin(theta), c * (1 - e2) * sin(lat)}
+		rx    = pos[0] - obs[0]
+		ry    = pos[1] - obs[1]
+		rz    = pos[2] - obs[2]
+	)
+
+	// Rotate the range vector to the topocentric south, east, zenith frame.
+	var (
+		s = sin(lat)*cos(theta)*rx + sin(lat)*sin(theta)*ry - cos(lat)*rz
+		e = -sin(theta)*rx + cos(theta)*ry
+		z = cos(lat)*cos(theta)*rx + cos(lat)*sin(theta)*ry + sin(lat)*rz
+	)
+
+	rng = math.Sqrt(rx*rx + ry*ry + rz*rz)
+	el = asin(z / rng)
+	az = norm360(atan2(e, -s))
+
+	return az, el, rng
+}
+
+// Sunlit returns true if a TEME position at a time is lit by the sun, ie,
+// not in the earth's cylindrical shadow.
+func Sunlit(pos [3]float64, t time.Time) bool {
+	ra, dec, _ := SunPosition(JulianDay(t))
+
+	var (
+		sun = [3]float64{cos(dec) * cos(ra), cos(dec) * sin(ra), sin(dec)}
+		dot = pos[0]*sun[0] + pos[1]*sun[1] + pos[2]*sun[2]
+	)
+	if dot > 0 {
+		return true
+	}
+
+	// Distance from the earth-sun line.
+	var (
+		r2 = pos[0]*pos[0] + pos[1]*pos[1] + pos[2]*pos[2]
+		d  = math.Sqrt(r2 - dot*dot)
+	)
+
+	return d > earthRadiusKM
+}
+
+// Passes returns up to n passes of the satellite over an observer at lat,
+// lon between from and to that reach at least minEl degrees. A pass that's
+// in progress at from is included.
+func (s *Satellite) Passes(from, to time.Time, lat, lon, minEl float64, n int) ([]Pass, error) {
+	elevation := func(t time.Time) (float64, error) {
+		pos, _, err := s.Position(t)
+		if err != nil {
+			return 0, err
+		}
+		_, el, _ := LookAngles(pos, t, lat, lon)
+		return el, nil
+	}
+
+	// Low earth orbits take a few minutes to cross the sky. Scan in steps
+	// shorter than that and bisect the horizon crossings.
+	const step = 30 * time.Second
+
+	// Start early enough to find the rise of a pass in progress.
+	var (
+		out   []Pass
+		start = from.Add(-20 * time.Minute)
+
+		prev, err = elevation(start)
+		cur       Pass
+		in        bool
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for t := start.Add(step); t.Before(to) && len(out) < n; t = t.Add(step) {
+		el, err := elevation(t)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		// Rise.
+		case prev < 0 && el >= 0:
+			cur, in = Pass{Rise: s.crossing(t.Add(-step), t, elevation), MaxElevation: -90}, true
+
+		// Set.
+		case in && prev >= 0 && el < 0:
+			in = false
+			cur.Set = s.crossing(t.Add(-step), t, elevation)
+			if cur.Set.Before(from) || cur.MaxElevation < minEl {
+				break
+			}
+
+			// Refine the highest point within a step of the sample.
+			cur.Max, cur.MaxElevation = s.peak(cur.Max.Add(-step), cur.Max.Add(step), elevation)
+
+			pos, _, _ := s.Position(cur.Max)
+			cur.MaxAzimuth, _, _ = LookAngles(pos, cur.Max, lat, lon)
+			cur.Sunlit = Sunlit(pos, cur.Max)
+
+			out = append(out, cur)
+		}
+
+		if in && el > cur.MaxElevation {
+			cur.Max, cur.MaxElevation = t, el
+		}
+		prev = el
+	}
+
+	return out, nil
+}
+
+// crossing bisects the time between a and b where the elevation crosses
+// the horizon to a second.
+func (s *Satellite) crossing(a, b time.Time, elevation func(time.Time) (float64, error)) time.Time {
+	ea, _ := elevation(a)
+	for b.Sub(a) > time.Second {
+		m := a.Add(b.Sub(a) / 2)
+		em, _ := elevation(m)
+		if (em < 0) == (ea < 0) {
+			a, ea = m, em
+		} else {
+			b = m
+		}
+	}
+
+	return b.Round(time.Second)
+}
+
+// peak returns the time and elevation of the highest point between a and b
+// with a ternary search.
+func (s *Satellite) peak(a, b time.Time, elevation func(time.Time) (float64, error)) (time.Time, float64) {
+	for b.Sub(a) > time.Second {
+		var (
+			m1 = a.Add(b.Sub(a) / 3)
+			m2 = b.Add(-b.Sub(a) / 3)
+		)
+		e1, _ := elevation(m1)
+		e2, _ := elevation(m2)
+		if e1 < e2 {
+			a = m1
+		} else {
+			b = m2
+		}
+	}
+
+	t := a.Add(b.Sub(a) / 2).Round(time.Second)
+	el, _ := elevation(t)
+	return t, el
+}
@@ -0,0 +1,57 @@
+package astro
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookAngles(t *testing.T) {
+	// 400 km above an observer on the equator.
+	var (
+		now   = time.Date(2025, 6, 21, 0, 0, 0, 0, time.UTC)
+		theta = SiderealTime(JulianDay(now), 77.59)
+		r     = 6378.137 + 400
+		pos   = [3]float64{r * cos(theta), r * sin(theta), 0}
+	)
+	if _, el, rng := LookAngles(pos, now, 0, 77.59); math.Abs(el-90) > 1e-6 || math.Abs(rng-400) > 1e-6 {
+		t.Errorf("want zenith, got el=%f rng=%f", el, rng)
+	}
+
+	// Above the prime meridian at midnight, in the earth's shadow, and above
+	// the antimeridian at noon.
+	theta = SiderealTime(JulianDay(now), 0)
+	if Sunlit([3]float64{r * cos(theta), r * sin(theta), 0}, now) || !Sunlit([3]float64{-r * cos(theta), -r * sin(theta), 0}, now) {
+		t.Errorf("unexpected sunlit status")
+	}
+}
+
+func TestPasses(t *testing.T) {
+	sats, err := ReadTLE(strings.NewReader(tle00005))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		s    = sats[0]
+		from = s.Epoch
+	)
+
+	passes, err := s.Passes(from, from.Add(48*time.Hour), 52.52437, 13.41053, 10, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(passes) == 0 {
+		t.Fatal("expected passes")
+	}
+	for _, p := range passes {
+		if !p.Rise.Before(p.Max) || !p.Max.Before(p.Set) || p.Set.Before(from) || p.MaxElevation < 10 {
+			t.Errorf("invalid pass: %+v", p)
+		}
+
+		pos, _, _ := s.Position(p.Rise)
+		if _, el, _ := LookAngles(pos, p.Rise, 52.52437, 13.41053); math.Abs(el) > 0.1 {
+			t.Errorf("expected rise at the horizon, got %f", el)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package astro
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Body is a solar system body that's visible to the naked eye.
+type Body string
+
+// Bodies supported by Position.
+const (
+	Sun     Body = "sun"
+	Moon    Body = "moon"
+	Mercury Body = "mercury"
+	Venus   Body = "venus"
+	Mars    Body = "mars"
+	Jupiter Body = "jupiter"
+	Saturn  Body = "saturn"
+)
+
+// Bodies is the list of supported bodies.
+var Bodies = []Body{Sun, Moon, Mercury, Venus, Mars, Jupiter, Saturn}
+
+const (
+	// Speed of light in AU per day for the light-time correction.
+	lightAUDay = 173.1446327
+
+	// KMPerAU is the number of km in an astronomical unit.
+	KMPerAU = 149597870.7
+
+	// Altitude in degrees of the centre of a star-like body at rise/set,
+	// accounting for atmospheric refraction.
+	starAltitude = -0.5667
+
+	// General precession in ecliptic longitude in degrees per Julian century.
+	precession = 1.3969713
+)
+
+// orbit is a planet's mean Keplerian elements at J2000.0 and their rates
+// per Julian century: the semi-major axis (AU), eccentricity, inclination,
+// mean longitude, longitude of the perihelion, and longitude of the
+// ascending node (degrees), relative to the J2000.0 ecliptic and equinox.
+type orbit struct {
+	a, e, i, l, peri, node       float64
+	da, de, di, dl, dperi, dnode float64
+}
+
+// Approximate elements from E.M. Standish, "Keplerian Elements for
+// Approximate Positions of the Major Planets" (JPL), valid from 1800 to
+// 2050 to within an arcminute or so for the inner planets and a few
+// arcminutes for the outer ones.
+var orbits = map[Body]orbit{
+	Mercury: {0.38709927, 0.20563593, 7.00497902, 252.25032350, 77.45779628, 48.33076593,
+		0.00000037, 0.00001906, -0.00594749, 149472.67411175, 0.16047689, -0.12534081},
+	Venus: {0.72333566, 0.00677672, 3.39467605, 181.97909950, 131.60246718, 76.67984255,
+		0.00000390, -0.00004107, -0.00078890, 58517.81538729, 0.00268329, -0.27769418},
+	Mars: {1.52371034, 0.09339410, 1.84969142, -4.55343205, -23.94362959, 49.55953891,
+		0.00001847, 0.00007882, -0.00813131, 19140.30268499, 0.44441088, -0.29257343},
+	Jupiter: {5.20288700, 0.04838624, 1.30439695, 34.39644051, 14.72847983, 100.47390909,
+		-0.00011607, -0.00013253, -0.00183714, 3034.74612775, 0.21252668, 0.20469106},
+	Saturn: {9.53667594, 0.05386179, 2.48599187, 49.95424423, 92.59887831, 113.66242448,
+		-0.00125060, -0.00050991, 0.00193609, 1222.49362201, -0.41897216, -0.28867794},
+}
+
+// earthOrbit is the orbit of the earth-moon barycentre.
+var earthOrbit = orbit{1.00000261, 0.01671123, -0.00001531, 100.46457166, 102.93768193, 0,
+	0.00000562, -0.00004392, -0.01294668, 35999.37244981, 0.32327364, 0}
+
+// The constellations the ecliptic passes through and the J2000.0 ecliptic
+// longitudes where it enters them, per the IAU boundaries.
+var zodiac = []struct {
+	lon  float64
+	name string
+}{
+	{29.09, "Aries"},
+	{53.47, "Taurus"},
+	{90.43, "Gemini"},
+	{118.26, "Cancer"},
+	{138.18, "Leo"},
+	{174.15, "Virgo"},
+	{218.02, "Libra"},
+	{241.13, "Scorpius"},
+	{247.70, "Ophiuchus"},
+	{266.30, "Sagittarius"},
+	{299.71, "Capricornus"},
+	{327.86, "Aquarius"},
+	{351.57, "Pisces"},
+}
+
+// ParseBody returns the body for a name, eg: mars.
+func ParseBody(name string) (Body, bool) {
+	b := Body(strings.ToLower(name))
+	for _, o := range Bodies {
+		if o == b {
+			return b, true
+		}
+	}
+
+	return "", false
+}
+
+// Name returns the capitalized name of the body, eg: Mars.
+func (b Body) Name() string {
+	return strings.ToUpper(string(b[:1])) + string(b[1:])
+}
+
+// Ecliptic returns the body's geocentric ecliptic longitude and latitude in
+// degrees of the date and its distance in AU for a Julian day.
+func (b Body) Ecliptic(jd float64) (lambda, beta, dist float64) {
+	switch b {
+	case Sun:
+		lambda, _, _, m, e := sunEcliptic(centuries(jd))
+
+		// Distance from the true anomaly.
+		v := m + sin(m)*1.914602 + sin(2*m)*0.019993 + sin(3*m)*0.000289
+		return lambda, 0, 1.000001018 * (1 - e*e) / (1 + e*cos(v))
+
+	case Moon:
+		lambda, beta, km := MoonEcliptic(jd)
+		return lambda, beta, km / KMPerAU
+	}
+
+	// Heliocentric positions of the planet and the earth, correcting for
+	// the time the light takes to reach the earth.
+	var (
+		o     = orbits[b]
+		earth = earthOrbit.position(jd)
+		g     [3]float64
+	)
+	for i, tau := 0, 0.0; i < 2; i++ {
+		p := o.position(jd - tau)
+		g = [3]float64{p[0] - earth[0], p[1] - earth[1], p[2] - earth[2]}
+		dist = math.Sqrt(g[0]*g[0] + g[1]*g[1] + g[2]*g[2])
+		tau = dist / lightAUDay
+	}
+
+	// Precess the J2000.0 longitude to the date.
+	lambda = norm360(atan2(g[1], g[0]) + precession*centuries(jd))
+	beta = atan2(g[2], math.Hypot(g[0], g[1]))
+
+	return lambda, beta, dist
+}
+
+// Position returns the body's geocentric right ascension and declination
+// in degrees of the date for a Julian day.
+func (b Body) Position(jd float64) (ra, dec float64) {
+	var (
+		lambda, beta, _ = b.Ecliptic(jd)
+		_, eps, _, _, _ = sunEcliptic(centuries(jd))
+	)
+
+	return Equatorial(lambda, beta, eps)
+}
+
+// Constellation returns the constellation along the ecliptic that the body
+// is in. Bodies that stray far from the ecliptic (the moon, Venus) can be
+// across the boundary of a neighbouring constellation.
+func (b Body) Constellation(jd float64) string {
+	lambda, _, _ := b.Ecliptic(jd)
+
+	// The boundaries are fixed to the J2000.0 equinox.
+	lon := norm360(lambda - precession*centuries(jd))
+
+	out := zodiac[len(zodiac)-1].name
+	for _, z := range zodiac {
+		if lon < z.lon {
+			break
+		}
+		out = z.name
+	}
+
+	return out
+}
+
+// RiseSet returns the body's rise and set times at lat, lon within the day
+// starting at the given time. rise or set is zero if the body doesn't rise
+// or set within the day.
+func (b Body) RiseSet(day time.Time, lat, lon float64) (rise, set time.Time) {
+	h0 := starAltitude
+	switch b {
+	case Sun:
+		h0 = 90 - ZenithSunrise
+	case Moon:
+		h0 = moonAltitude
+	}
+
+	return riseSet(day, lat, lon, h0, b.Position)
+}
+
+// position returns the heliocentric ecliptic coordinates in AU, relative to
+// the J2000.0 ecliptic and equinox, for a Julian day.
+func (o orbit) position(jd float64) [3]float64 {
+	var (
+		t    = centuries(jd)
+		a    = o.a + o.da*t
+		e    = o.e + o.de*t
+		i    = o.i + o.di*t
+		l    = o.l + o.dl*t
+		peri = o.peri + o.dperi*t
+		node = o.node + o.dnode*t
+
+		// Argument of the perihelion and the mean anomaly.
+		w = peri - node
+		m = math.Remainder(l-peri, 360) * deg
+	)
+
+	// Solve Kepler's equation for the eccentric anomaly (radians).
+	ea := m + e*math.Sin(m)
+	for n := 0; n < 10; n++ {
+		d := (ea - e*math.Sin(ea) - m) / (1 - e*math.Cos(ea))
+		ea -= d
+		if math.Abs(d) < 1e-12 {
+			break
+		}
+	}
+
+	// Coordinates in the orbital plane, rotated to the ecliptic.
+	var (
+		x = a * (math.Cos(ea) - e)
+		y = a * math.Sqrt(1-e*e) * math.Sin(ea)
+	)
+
+	return [3]float64{
+		(cos(w)*cos(node)-sin(w)*sin(node)*cos(i))*x + (-sin(w)*cos(node)-cos(w)*sin(node)*cos(i))*y,
+		(cos(w)*sin(node)+sin(w)*cos(node)*cos(i))*x + (-sin(w)*sin(node)+cos(w)*cos(node)*cos(i))*y,
+		sin(w)*sin(i)*x + cos(w)*sin(i)*y,
+	}
+}
@@ -0,0 +1,44 @@
+package astro
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPlanets(t *testing.T) {
+	// Meeus, Astronomical Algorithms, example 33.a: Venus on 1992-12-20.
+	ra, dec := Venus.Position(2448976.5)
+	if math.Abs(ra-316.1727) > 0.1 || math.Abs(dec+18.8880) > 0.1 {
+		t.Errorf("venus: unexpected ra, dec: %f, %f", ra, dec)
+	}
+
+	// The sun's position matches the solar equations.
+	jd := JulianDay(time.Date(2025, 12, 25, 0, 0, 0, 0, time.UTC))
+	ra1, dec1 := Sun.Position(jd)
+	ra2, dec2, _ := SunPosition(jd)
+	if math.Abs(ra1-ra2) > 1e-9 || math.Abs(dec1-dec2) > 1e-9 {
+		t.Errorf("sun: want %f, %f got %f, %f", ra2, dec2, ra1, dec1)
+	}
+	if c := Sun.Constellation(jd); c != "Sagittarius" {
+		t.Errorf("sun: want Sagittarius got %s", c)
+	}
+	if _, _, d := Sun.Ecliptic(jd); math.Abs(d-0.9836) > 0.001 {
+		t.Errorf("sun: unexpected distance %f", d)
+	}
+}
+
+func TestBodyRiseSet(t *testing.T) {
+	// The sun's rise and set match the solar equations to a minute.
+	var (
+		berlin, _ = time.LoadLocation("Europe/Berlin")
+		day       = time.Date(2025, 6, 21, 0, 0, 0, 0, berlin)
+		rise, set = Sun.RiseSet(day, 52.52437, 13.41053)
+	)
+	if s := rise.In(berlin).Round(time.Minute).Format("15:04"); s != "04:43" {
+		t.Errorf("sunrise: want 04:43 got %s", s)
+	}
+	if s := set.In(berlin).Round(time.Minute).Format("15:04"); s != "21:33" {
+		t.Errorf("sunset: want 21:33 got %s", s)
+	}
+}
@@ -0,0 +1,358 @@
+package astro
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// WGS-72 constants that the SGP4 model and the published element sets use.
+const (
+	earthRadiusKM = 6378.135
+	earthMu       = 398600.8
+	j2            = 0.001082616
+	j3            = -0.00000253881
+	j4            = -0.00000165597
+	j3oj2         = j3 / j2
+	x2o3          = 2.0 / 3.0
+	twoPi         = 2 * math.Pi
+
+	// Minutes a day.
+	minPerDay = 1440.0
+
+	// Orbits with longer periods (minutes) need the deep space (SDP4)
+	// perturbations of the sun and the moon, which aren't implemented.
+	deepSpacePeriod = 225
+)
+
+// xke is sqrt(GM) in earth radii^1.5 per minute.
+var xke = 60 / math.Sqrt(earthRadiusKM*earthRadiusKM*earthRadiusKM/earthMu)
+
+var (
+	// ErrDeepSpace is returned for satellites in deep space orbits, eg:
+	// geostationary and GPS, that SGP4 doesn't support.
+	ErrDeepSpace = errors.New("deep space orbits are not supported")
+
+	// ErrDecayed is returned when the propagated orbit is no longer valid,
+	// eg: the satellite has decayed.
+	ErrDecayed = errors.New("satellite has decayed")
+)
+
+// Satellite is a satellite's orbit from a two-line element set, initialized
+// for propagation with the near earth SGP4 model.
+type Satellite struct {
+	// Name and NORAD catalog number.
+	Name string
+	ID   int
+
+	// Epoch of the elements.
+	Epoch time.Time
+
+	// Mean elements in radians, mean motion in radians/minute, and the
+	// drag term in inverse earth radii.
+	bstar, incl, node, ecc, argp, mo, no float64
+
+	// Model coefficients from initialization.
+	isimp                                      bool
+	aycof, con41, cc1, cc4, cc5, d2, d3, d4    float64
+	delmo, eta, argpdot, omgcof, sinmao        float64
+	t2cof, t3cof, t4cof, t5cof, x1mth2, x7thm1 float64
+	mdot, nodedot, xlcof, xmcof, nodecf        float64
+}
+
+// init initializes the SGP4 model coefficients from the mean elements, per
+// Spacetrack Report #3 with the revisions in Vallado et al., "Revisiting
+// Spacetrack Report #3" (2006).
+func (s *Satellite) init() error {
+	var (
+		eccsq  = s.ecc * s.ecc
+		omeosq = 1 - eccsq
+		rteosq = math.Sqrt(omeosq)
+		cosio  = math.Cos(s.incl)
+		cosio2 = cosio * cosio
+		sinio  = math.Sin(s.incl)
+	)
+
+	// Un-Kozai the mean motion.
+	ak := math.Pow(xke/s.no, x2o3)
+	d1 := 0.75 * j2 * (3*cosio2 - 1) / (rteosq * omeosq)
+	del := d1 / (ak * ak)
+	adel := ak * (1 - del*del - del*(1.0/3+134*del*del/81))
+	del = d1 / (adel * adel)
+	s.no = s.no / (1 + del)
+
+	if twoPi/s.no >= deepSpacePeriod {
+		return ErrDeepSpace
+	}
+
+	var (
+		ao    = math.Pow(xke/s.no, x2o3)
+		po    = ao * omeosq
+		con42 = 1 - 5*cosio2
+		posq  = po * po
+		rp    = ao * (1 - s.ecc)
+	)
+	s.con41 = -con42 - cosio2 - cosio2
+
+	// Simplified drag for perigees below 220 km.
+	s.isimp = rp < 220/earthRadiusKM+1
+
+	// The atmospheric density parameters for low perigees.
+	var (
+		sfour  = 78/earthRadiusKM + 1
+		qzms24 = math.Pow((120-78)/earthRadiusKM, 4)
+		perige = (rp - 1) * earthRadiusKM
+	)
+	if perige < 156 {
+		sfour = perige - 78
+		if perige < 98 {
+			sfour = 20
+		}
+		qzms24 = math.Pow((120-sfour)/earthRadiusKM, 4)
+		sfour = sfour/earthRadiusKM + 1
+	}
+
+	var (
+		pinvsq = 1 / posq
+		tsi    = 1 / (ao - sfour)
+		etasq  float64
+		eeta   float64
+	)
+	s.eta = ao * s.ecc * tsi
+	etasq = s.eta * s.eta
+	eeta = s.ecc * s.eta
+
+	var (
+		psisq = math.Abs(1 - etasq)
+		coef  = qzms24 * math.Pow(tsi, 4)
+		coef1 = coef / math.Pow(psisq, 3.5)
+		cc2   = coef1 * s.no * (ao*(1+1.5*etasq+eeta*(4+etasq)) +
+			0.375*j2*tsi/psisq*s.con41*(8+3*etasq*(8+etasq)))
+		cc3 float64
+	)
+	s.cc1 = s.bstar * cc2
+	if s.ecc > 1e-4 {
+		cc3 = -2 * coef * tsi * j3oj2 * s.no * sinio / s.ecc
+	}
+	s.x1mth2 = 1 - cosio2
+	s.cc4 = 2 * s.no * coef1 * ao * omeosq * (s.eta*(2+0.5*etasq) + s.ecc*(0.5+2*etasq) -
+		j2*tsi/(ao*psisq)*(-3*s.con41*(1-2*eeta+etasq*(1.5-0.5*eeta))+
+			0.75*s.x1mth2*(2*etasq-eeta*(1+etasq))*math.Cos(2*s.argp)))
+	s.cc5 = 2 * coef1 * ao * omeosq * (1 + 2.75*(etasq+eeta) + eeta*etasq)
+
+	// Secular rates of the mean anomaly, the argument of perigee, and the node.
+	var (
+		cosio4 = cosio2 * cosio2
+		temp1  = 1.5 * j2 * pinvsq * s.no
+		temp2  = 0.5 * temp1 * j2 * pinvsq
+		temp3  = -0.46875 * j4 * pinvsq * pinvsq * s.no
+		xhdot1 = -temp1 * cosio
+	)
+	s.mdot = s.no + 0.5*temp1*rteosq*s.con41 + 0.0625*temp2*rteosq*(13-78*cosio2+137*cosio4)
+	s.argpdot = -0.5*temp1*con42 + 0.0625*temp2*(7-114*cosio2+395*cosio4) + temp3*(3-36*cosio2+49*cosio4)
+	s.nodedot = xhdot1 + (0.5*temp2*(4-19*cosio2)+2*temp3*(3-7*cosio2))*cosio
+
+	s.omgcof = s.bstar * cc3 * math.Cos(s.argp)
+	if s.ecc > 1e-4 {
+		s.xmcof = -x2o3 * coef * s.bstar / eeta
+	}
+	s.nodecf = 3.5 * omeosq * xhdot1 * s.cc1
+	s.t2cof = 1.5 * s.cc1
+
+	// Avoid a division by zero for inclinations of 180 degrees.
+	if math.Abs(cosio+1) > 1.5e-12 {
+		s.xlcof = -0.25 * j3oj2 * sinio * (3 + 5*cosio) / (1 + cosio)
+	} else {
+		s.xlcof = -0.25 * j3oj2 * sinio * (3 + 5*cosio) / 1.5e-12
+	}
+	s.aycof = -0.5 * j3oj2 * sinio
+	s.delmo = math.Pow(1+s.eta*math.Cos(s.mo), 3)
+	s.sinmao = math.Sin(s.mo)
+	s.x7thm1 = 7*cosio2 - 1
+
+	if !s.isimp {
+		var (
+			cc1sq = s.cc1 * s.cc1
+			temp  float64
+		)
+		s.d2 = 4 * ao * tsi * cc1sq
+		temp = s.d2 * tsi * s.cc1 / 3
+		s.d3 = (17*ao + sfour) * temp
+		s.d4 = 0.5 * temp * ao * tsi * (221*ao + 31*sfour) * s.cc1
+		s.t3cof = s.d2 + 2*cc1sq
+		s.t4cof = 0.25 * (3*s.d3 + s.cc1*(12*s.d2+10*cc1sq))
+		s.t5cof = 0.2 * (3*s.d4 + 12*s.cc1*s.d3 + 6*s.d2*s.d2 + 15*cc1sq*(2*s.d2+cc1sq))
+	}
+
+	return nil
+}
+
+// Position returns the satellite's position (km) and velocity (km/s) in the
+// true equator, mean equinox (TEME) frame at a time.
+func (s *Satellite) Position(t time.Time) (pos, vel [3]float64, err error) {
+	var (
+		tsince = t.Sub(s.Epoch).Minutes()
+
+		// Secular gravity and atmospheric drag.
+		xmdf   = s.mo + s.mdot*tsince
+		argpdf = s.argp + s.argpdot*tsince
+		nodedf = s.node + s.nodedot*tsince
+		argpm  = argpdf
+		mm     = xmdf
+		t2     = tsince * tsince
+		nodem  = nodedf + s.nodecf*t2
+		tempa  = 1 - s.cc1*tsince
+		tempe  = s.bstar * s.cc4 * tsince
+		templ  = s.t2cof * t2
+	)
+	if !s.isimp {
+		var (
+			delomg = s.omgcof * tsince
+			delm   = s.xmcof * (math.Pow(1+s.eta*math.Cos(xmdf), 3) - s.delmo)
+			temp   = delomg + delm
+			t3     = t2 * tsince
+			t4     = t3 * tsince
+		)
+		mm = xmdf + temp
+		argpm = argpdf - temp
+		tempa = tempa - s.d2*t2 - s.d3*t3 - s.d4*t4
+		tempe = tempe + s.bstar*s.cc5*(math.Sin(mm)-s.sinmao)
+		templ = templ + s.t3cof*t3 + t4*(s.t4cof+tsince*s.t5cof)
+	}
+
+	var (
+		am = math.Pow(xke/s.no, x2o3) * tempa * tempa
+		nm = xke / math.Pow(am, 1.5)
+		em = s.ecc - tempe
+	)
+	if em >= 1 || em < -0.001 || am < 0.95 {
+		return pos, vel, ErrDecayed
+	}
+	if em < 1e-6 {
+		em = 1e-6
+	}
+
+	mm = mm + s.no*templ
+	xlm := math.Mod(mm+argpm+nodem, twoPi)
+	nodem = math.Mod(nodem, twoPi)
+	argpm = math.Mod(argpm, twoPi)
+
+	// Long period periodics.
+	var (
+		axnl = em * math.Cos(argpm)
+		temp = 1 / (am * (1 - em*em))
+		aynl = em*math.Sin(argpm) + temp*s.aycof
+		xl   = xlm + temp*s.xlcof*axnl
+	)
+
+	// Solve Kepler's equation.
+	var (
+		u              = math.Mod(xl-nodem, twoPi)
+		eo1            = u
+		sineo1, coseo1 float64
+	)
+	for i, tem5 := 0, 1.0; math.Abs(tem5) >= 1e-12 && i < 10; i++ {
+		sineo1, coseo1 = math.Sincos(eo1)
+		tem5 = (u - aynl*coseo1 + axnl*sineo1 - eo1) / (1 - coseo1*axnl - sineo1*aynl)
+		if math.Abs(tem5) >= 0.95 {
+			tem5 = math.Copysign(0.95, tem5)
+		}
+		eo1 += tem5
+	}
+	sineo1, coseo1 = math.Sincos(eo1)
+
+	// Short period preliminary quantities.
+	var (
+		ecose = axnl*coseo1 + aynl*sineo1
+		esine = axnl*sineo1 - aynl*coseo1
+		el2   = axnl*axnl + aynl*aynl
+		pl    = am * (1 - el2)
+	)
+	if pl < 0 {
+		return pos, vel, ErrDecayed
+	}
+
+	var (
+		rl     = am * (1 - ecose)
+		rdotl  = math.Sqrt(am) * esine / rl
+		rvdotl = math.Sqrt(pl) / rl
+		betal  = math.Sqrt(1 - el2)
+	)
+	temp = esine / (1 + betal)
+
+	var (
+		sinu  = am / rl * (sineo1 - aynl - axnl*temp)
+		cosu  = am / rl * (coseo1 - axnl + aynl*temp)
+		su    = math.Atan2(sinu, cosu)
+		sin2u = (cosu + cosu) * sinu
+		cos2u = 1 - 2*sinu*sinu
+		temp1 = 0.5 * j2 / pl
+		temp2 = temp1 / pl
+		cosi  = math.Cos(s.incl)
+		sini  = math.Sin(s.incl)
+	)
+
+	// Update for short period periodics.
+	var (
+		mrt   = rl*(1-1.5*temp2*betal*s.con41) + 0.5*temp1*s.x1mth2*cos2u
+		xnode = nodem + 1.5*temp2*cosi*sin2u
+		xinc  = s.incl + 1.5*temp2*cosi*sini*cos2u
+		mvt   = rdotl - nm*temp1*s.x1mth2*sin2u/xke
+		rvdot = rvdotl + nm*temp1*(s.x1mth2*cos2u+1.5*s.con41)/xke
+	)
+	su = su - 0.25*temp2*s.x7thm1*sin2u
+	if mrt < 1 {
+		return pos, vel, ErrDecayed
+	}
+
+	// Orientation vectors.
+	var (
+		sinsu, cossu = math.Sincos(su)
+		snod, cnod   = math.Sincos(xnode)
+		sinx, cosx   = math.Sincos(xinc)
+		xmx          = -snod * cosx
+		xmy          = cnod * cosx
+		uv           = [3]float64{xmx*sinsu + cnod*cossu, xmy*sinsu + snod*cossu, sinx * sinsu}
+		vv           = [3]float64{xmx*cossu - cnod*sinsu, xmy*cossu - snod*sinsu, sinx * cossu}
+		vkmpersec    = earthRadiusKM * xke / 60
+	)
+	for i := range pos {
+		pos[i] = mrt * uv[i] * earthRadiusKM
+		vel[i] = (mvt*uv[i] + rvdot*vv[i]) * vkmpersec
+	}
+
+	return pos, vel, nil
+}
+
+// Geodetic returns the WGS-84 latitude and longitude in degrees and the
+// altitude in km of a TEME position at a time.
+func Geodetic(pos [3]float64, t time.Time) (lat, lon, alt float64) {
+	const (
+		a  = 6378.137
+		f  = 1 / 298.257223563
+		e2 = f * (2 - f)
+	)
+
+	// Rotate by the sidereal time to the earth fixed frame.
+	var (
+		g = SiderealTime(JulianDay(t), 0)
+		x = cos(g)*pos[0] + sin(g)*pos[1]
+		y = -sin(g)*pos[0] + cos(g)*pos[1]
+		z = pos[2]
+		p = math.Hypot(x, y)
+	)
+
+	// Iterate for the latitude on the ellipsoid.
+	phi := math.Atan2(z, p*(1-e2))
+	for i := 0; i < 5; i++ {
+		sp := math.Sin(phi)
+		phi = math.Atan2(z+a/math.Sqrt(1-e2*sp*sp)*e2*sp, p)
+	}
+
+	sp, cp := math.Sincos(phi)
+	lat = phi / deg
+	lon = math.Remainder(math.Atan2(y, x)/deg, 360)
+	alt = p*cp + z*sp - a*math.Sqrt(1-e2*sp*sp)
+
+	return lat, lon, alt
+}
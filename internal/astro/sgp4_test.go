@@ -0,0 +1,88 @@
+package astro
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Vallado's SGP4 verification satellite 00005 and its published TEME
+// positions (km) and velocities (km/s).
+const tle00005 = `TEST SAT
+1 00005U 58002B   00179.78495062  .00000023  00000-0  28098-4 0  4753
+2 00005  34.2682 348.7242 1859667 331.7664  19.3264 10.82419157413667`
+
+func TestSGP4(t *testing.T) {
+	sats, err := ReadTLE(strings.NewReader(tle00005))
+	if err != nil || len(sats) != 1 {
+		t.Fatalf("error reading TLE: %v", err)
+	}
+	s := sats[0]
+	if s.ID != 5 || s.Name != "TEST SAT" {
+		t.Errorf("unexpected satellite: %d %s", s.ID, s.Name)
+	}
+
+	tests := []struct {
+		mins     float64
+		pos, vel [3]float64
+	}{
+		{0, [3]float64{7022.46529266, -1400.08296755, 0.03995155}, [3]float64{1.893841015, 6.405893759, 4.534807250}},
+		{360, [3]float64{-7154.03120202, -3783.17682504, -3536.19412294}, [3]float64{4.741887409, -4.151817765, -2.093935425}},
+	}
+	for _, tc := range tests {
+		pos, vel, err := s.Position(s.Epoch.Add(time.Duration(tc.mins * float64(time.Minute))))
+		if err != nil {
+			t.Fatalf("%v: %v", tc.mins, err)
+		}
+		for i := range pos {
+			if math.Abs(pos[i]-tc.pos[i]) > 1e-3 || math.Abs(vel[i]-tc.vel[i]) > 1e-6 {
+				t.Errorf("%v: want %v %v got %v %v", tc.mins, tc.pos, tc.vel, pos, vel)
+				break
+			}
+		}
+	}
+}
+
+func TestDeepSpace(t *testing.T) {
+	// 00005 with a geostationary mean motion.
+	l := strings.Split(tle00005, "\n")
+	if _, err := ParseTLE(l[0], l[1], l[2][:52]+" 1.00270000"+l[2][63:]); err != ErrDeepSpace {
+		t.Errorf("expected ErrDeepSpace, got %v", err)
+	}
+}
+
+func TestReadTLEInvalid(t *testing.T) {
+	l := strings.Split(tle00005, "\n")
+	var (
+		badNum  = l[1][:2] + "0X005" + l[1][7:]
+		badMo   = l[2][:52] + "     x     " + l[2][63:]
+		deep    = l[2][:52] + " 1.00270000" + l[2][63:]
+		entries = []string{
+			"BAD CATALOG", badNum, l[2],
+			"MISSING LINE", l[1],
+			"BAD MEAN MOTION", l[1], badMo,
+			"DEEP", l[1], deep,
+			tle00005,
+			"INCOMPLETE", l[1],
+		}
+	)
+
+	// Invalid entries are skipped without losing the valid ones.
+	sats, err := ReadTLE(strings.NewReader(strings.Join(entries, "\n")))
+	if err != nil || len(sats) != 1 || sats[0].Name != "TEST SAT" {
+		t.Fatalf("unexpected satellites: %v %v", sats, err)
+	}
+
+	if _, err := ReadTLE(strings.NewReader(strings.Join(entries[:8], "\n"))); err == nil {
+		t.Errorf("expected error without valid entries")
+	}
+}
+
+func TestGeodetic(t *testing.T) {
+	// Above the north pole.
+	lat, _, alt := Geodetic([3]float64{0, 0, 7000}, time.Now())
+	if math.Abs(lat-90) > 1e-6 || math.Abs(alt-(7000-6356.752)) > 1e-3 {
+		t.Errorf("unexpected lat, alt: %f, %f", lat, alt)
+	}
+}
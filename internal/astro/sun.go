@@ -0,0 +1,126 @@
+package astro
+
+import (
+	"math"
+	"time"
+)
+
+// Zenith angles in degrees of the sun's centre for rise/set and twilight
+// events. Sunrise and sunset account for atmospheric refraction and the
+// sun's apparent radius.
+const (
+	ZenithSunrise      = 90.833
+	ZenithCivil        = 96
+	ZenithNautical     = 102
+	ZenithAstronomical = 108
+)
+
+// SunPosition returns the sun's apparent right ascension and declination
+// in degrees and the equation of time in minutes for a Julian day, using
+// the NOAA solar equations.
+func SunPosition(jd float64) (ra, dec, eqTime float64) {
+	var (
+		t                     = centuries(jd)
+		lambda, eps, l0, m, e = sunEcliptic(t)
+	)
+
+	ra, dec = Equatorial(lambda, 0, eps)
+
+	y := tan(eps/2) * tan(eps/2)
+	eqTime = 4 / deg * (y*sin(2*l0) - 2*e*sin(m) + 4*e*y*sin(m)*cos(2*l0) -
+		0.5*y*y*sin(4*l0) - 1.25*e*e*sin(2*m))
+
+	return ra, dec, eqTime
+}
+
+// SunLongitude returns the sun's apparent ecliptic longitude in degrees
+// for a Julian day.
+func SunLongitude(jd float64) float64 {
+	lambda, _, _, _, _ := sunEcliptic(centuries(jd))
+	return lambda
+}
+
+// sunEcliptic returns the sun's apparent ecliptic longitude, the apparent
+// obliquity of the ecliptic, the sun's geometric mean longitude and anomaly,
+// and the eccentricity of earth's orbit, all in degrees, for Julian
+// centuries since J2000.0.
+func sunEcliptic(t float64) (lambda, eps, l0, m, e float64) {
+	l0 = norm360(280.46646 + t*(36000.76983+t*0.0003032))
+	m = 357.52911 + t*(35999.05029-0.0001537*t)
+	e = 0.016708634 - t*(0.000042037+0.0000001267*t)
+
+	// Equation of the centre.
+	c := sin(m)*(1.914602-t*(0.004817+0.000014*t)) + sin(2*m)*(0.019993-0.000101*t) + sin(3*m)*0.000289
+
+	// Apparent longitude, corrected for nutation and aberration.
+	omega := 125.04 - 1934.136*t
+	lambda = norm360(l0 + c - 0.00569 - 0.00478*sin(omega))
+
+	eps = obliquity(t) + 0.00256*cos(omega)
+
+	return lambda, eps, l0, m, e
+}
+
+// obliquity returns the mean obliquity of the ecliptic in degrees for
+// Julian centuries since J2000.0.
+func obliquity(t float64) float64 {
+	return 23 + (26+(21.448-t*(46.815+t*(0.00059-t*0.001813)))/60)/60
+}
+
+// SolarNoon returns the time of solar noon at a longitude on the given
+// date (in the date's location).
+func SolarNoon(date time.Time, lon float64) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Start with the mean solar noon and correct for the equation of time
+	// at noon, twice, as it changes slightly through the day.
+	noon := day.Add(time.Duration((720 - 4*lon) * float64(time.Minute)))
+	for i := 0; i < 2; i++ {
+		_, _, eq := SunPosition(JulianDay(noon))
+		noon = day.Add(time.Duration((720 - 4*lon - eq) * float64(time.Minute)))
+	}
+
+	// For zones far from their meridian, the solar noon in UTC may fall on
+	// a different local date.
+	var (
+		ly, lm, ld = noon.In(date.Location()).Date()
+		local      = time.Date(ly, lm, ld, 0, 0, 0, 0, time.UTC)
+	)
+
+	return noon.Add(day.Sub(local))
+}
+
+// SunEvent returns the time when the sun's centre crosses the given zenith
+// angle (degrees) before (rising) or after the solar noon on the date at
+// lat, lon. ok is false if the sun doesn't cross the zenith angle on the
+// date (polar day or night). above is true if the sun stays above it.
+func SunEvent(date time.Time, lat, lon, zenith float64, rising bool) (t time.Time, above, ok bool) {
+	var (
+		noon = SolarNoon(date, lon)
+		tm   = noon
+	)
+
+	// Iterate using the sun's position at the event's time.
+	for i := 0; i < 3; i++ {
+		_, dec, eq := SunPosition(JulianDay(tm))
+
+		cosH := (cos(zenith) - sin(lat)*sin(dec)) / (cos(lat) * cos(dec))
+		if cosH < -1 {
+			return time.Time{}, true, false
+		}
+		if cosH > 1 {
+			return time.Time{}, false, false
+		}
+
+		h := math.Acos(cosH) / deg
+		if rising {
+			h = -h
+		}
+
+		// Minutes from 00:00 UTC of the noon's day.
+		day := time.Date(noon.Year(), noon.Month(), noon.Day(), 0, 0, 0, 0, time.UTC)
+		tm = day.Add(time.Duration((720 - 4*(lon-h) - eq) * float64(time.Minute)))
+	}
+
+	return tm, false, true
+}
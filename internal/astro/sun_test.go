@@ -0,0 +1,52 @@
+package astro
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunEvents(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	london, _ := time.LoadLocation("Europe/London")
+	sydney, _ := time.LoadLocation("Australia/Sydney")
+	tromso, _ := time.LoadLocation("Europe/Oslo")
+
+	// Published NOAA / almanac times, to the minute.
+	tests := []struct {
+		date            time.Time
+		lat, lon        float64
+		rise, noon, set string
+	}{
+		{time.Date(2025, 6, 21, 0, 0, 0, 0, berlin), 52.52437, 13.41053, "04:43", "13:08", "21:33"},
+		{time.Date(2024, 12, 21, 0, 0, 0, 0, london), 51.50853, -0.12574, "08:04", "11:59", "15:54"},
+		{time.Date(2025, 6, 21, 0, 0, 0, 0, sydney), -33.86785, 151.20732, "07:00", "11:57", "16:54"},
+	}
+	for _, tc := range tests {
+		var (
+			rise, _, ok1 = SunEvent(tc.date, tc.lat, tc.lon, ZenithSunrise, true)
+			set, _, ok2  = SunEvent(tc.date, tc.lat, tc.lon, ZenithSunrise, false)
+			noon         = SolarNoon(tc.date, tc.lon)
+			loc          = tc.date.Location()
+		)
+		if !ok1 || !ok2 {
+			t.Errorf("%v: expected events", tc.date)
+			continue
+		}
+		for _, c := range []struct {
+			got  time.Time
+			want string
+		}{{rise, tc.rise}, {noon, tc.noon}, {set, tc.set}} {
+			if s := c.got.In(loc).Round(time.Minute).Format("15:04"); s != c.want {
+				t.Errorf("%v: want %s got %s", tc.date, c.want, s)
+			}
+		}
+	}
+
+	// Polar night and day in Tromsø.
+	if _, above, ok := SunEvent(time.Date(2025, 12, 21, 0, 0, 0, 0, tromso), 69.6489, 18.95508, ZenithSunrise, true); ok || above {
+		t.Errorf("expected polar night")
+	}
+	if _, above, ok := SunEvent(time.Date(2025, 6, 21, 0, 0, 0, 0, tromso), 69.6489, 18.95508, ZenithSunrise, true); ok || !above {
+		t.Errorf("expected polar day")
+	}
+}
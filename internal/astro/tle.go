@@ -0,0 +1,177 @@
+package astro
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTLE parses a two-line element set and returns the satellite
+// initialized for propagation.
+func ParseTLE(name, line1, line2 string) (*Satellite, error) {
+	line1, line2 = strings.TrimRight(line1, " \r"), strings.TrimRight(line2, " \r")
+	if len(line1) < 69 || len(line2) < 69 || line1[0] != '1' || line2[0] != '2' {
+		return nil, errors.New("invalid TLE lines")
+	}
+
+	var (
+		err error
+		num = func(s string) float64 {
+			if err != nil {
+				return 0
+			}
+			var f float64
+			f, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+			return f
+		}
+
+		s = &Satellite{Name: strings.TrimSpace(strings.TrimPrefix(name, "0 "))}
+	)
+
+	s.ID, err = strconv.Atoi(strings.TrimSpace(line1[2:7]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid catalog number: %s", line1[2:7])
+	}
+
+	var (
+		year  = num(line1[18:20])
+		day   = num(line1[20:32])
+		bstar = expNum(line1[53:61], &err)
+
+		incl = num(line2[8:16])
+		node = num(line2[17:25])
+		ecc  = num("." + line2[26:33])
+		argp = num(line2[34:42])
+		mo   = num(line2[43:51])
+		no   = num(line2[52:63])
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLE for %d: %v", s.ID, err)
+	}
+
+	// Two digit years from 57 (Sputnik) are in the 1900s.
+	if year < 57 {
+		year += 2000
+	} else {
+		year += 1900
+	}
+	s.Epoch = time.Date(int(year), 1, 1, 0, 0, 0, 0, time.UTC).
+		Add(time.Duration((day - 1) * float64(24*time.Hour)))
+
+	s.bstar = bstar
+	s.incl = incl * deg
+	s.node = node * deg
+	s.ecc = ecc
+	s.argp = argp * deg
+	s.mo = mo * deg
+	s.no = no * twoPi / minPerDay
+
+	if s.no <= 0 {
+		return nil, fmt.Errorf("invalid mean motion for %d", s.ID)
+	}
+
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// ReadTLE reads satellites from element sets in the three line format (a
+// name line followed by the two element lines) that Celestrak publishes.
+// Satellites in deep space orbits are skipped, and so are invalid element
+// sets, which are logged. It only fails if there are no valid element sets.
+func ReadTLE(r io.Reader) ([]*Satellite, error) {
+	var (
+		out     []*Satellite
+		lines   []string
+		lastErr error
+		sc      = bufio.NewScanner(r)
+	)
+	for sc.Scan() {
+		l := strings.TrimRight(sc.Text(), " \r")
+		if l == "" {
+			continue
+		}
+
+		lines = append(lines, l)
+		if len(lines) < 3 {
+			continue
+		}
+
+		// If a line is missing, skip ahead to the next name line so that
+		// the element sets that follow are still read.
+		if !strings.HasPrefix(lines[1], "1 ") || !strings.HasPrefix(lines[2], "2 ") {
+			log.Printf("skipping invalid TLE line: %s", lines[0])
+			lastErr = errors.New("invalid TLE lines")
+			lines = append(lines[:0], lines[1:]...)
+			continue
+		}
+
+		s, err := ParseTLE(lines[0], lines[1], lines[2])
+		lines = lines[:0]
+		if err == ErrDeepSpace {
+			continue
+		}
+		if err != nil {
+			log.Printf("skipping invalid TLE: %v", err)
+			lastErr = err
+			continue
+		}
+		out = append(out, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) > 0 {
+		log.Printf("skipping incomplete TLE at the end: %s", lines[0])
+		lastErr = errors.New("incomplete TLE at the end")
+	}
+
+	if len(out) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	return out, nil
+}
+
+// expNum parses a TLE number with an implied leading decimal point and an
+// exponent, eg: " 28098-4" = 0.28098e-4.
+func expNum(s string, err *error) float64 {
+	if *err != nil {
+		return 0
+	}
+
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		*err = fmt.Errorf("invalid number: %s", s)
+		return 0
+	}
+
+	var (
+		sign = 1.0
+		m    = s[:len(s)-2]
+		e    = s[len(s)-2:]
+	)
+	if m != "" && (m[0] == '-' || m[0] == '+') {
+		if m[0] == '-' {
+			sign = -1
+		}
+		m = m[1:]
+	}
+
+	mant, err1 := strconv.ParseFloat("."+m, 64)
+	exp, err2 := strconv.Atoi(e)
+	if err1 != nil || err2 != nil {
+		*err = fmt.Errorf("invalid number: %s", s)
+		return 0
+	}
+
+	return sign * mant * math.Pow(10, float64(exp))
+}
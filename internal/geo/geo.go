@@ -2,8 +2,12 @@
 package geo
 
 import (
+	"bufio"
 	"encoding/csv"
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"os"
 	"regexp"
 	"sort"
@@ -14,12 +18,54 @@ import (
 
 // Geo is the geolocation controller.
 type Geo struct {
-	locations []Location
+	// Flat list of all the locations in a compact form. Everything else
+	// refers to locations by their index in this list.
+	locations []record
 
-	// { $keyword: { $timezone: $country_code }}
-	tzMap map[string][]Location
+	// Interned timezones, country codes, and admin1 codes that records
+	// refer to by their index in these lists.
+	zones       []string
+	zoneLocs    []*time.Location
+	countries   []string
+	admin1Codes []string
 
-	count int
+	// Normalized timezone names, { $asia/kolkata: $index in zones }.
+	zoneMap map[string]int
+
+	// City names and cities in timezone names, { $keyword: [$index in locations] }.
+	tzMap map[string][]int32
+
+	// Alternate and ASCII names of locations, { $keyword: [$index in locations] }.
+	// They're only looked up when there are no matches in tzMap.
+	aliasMap map[string][]int32
+
+	// Admin1 (state, province, region) names, { $country.$admin1_code: $name }.
+	admin1 map[string]string
+
+	// Spatial grid index of 1 degree cells, [$row*360+$col][$index in locations].
+	grid [][]int32
+
+	// Keywords in tzMap and aliasMap, and the index of them used for
+	// suggestions, { $length.$first or $last char: [$index in names] }.
+	names   []string
+	suggest map[suggestKey][]nameRef
+}
+
+// Opt contains config options for Geo.
+type Opt struct {
+	// Path to the geonames.org cities file, eg: cities15000.txt, or
+	// allCountries.txt. Only populated places (feature class P) are loaded.
+	FilePath string
+
+	// Optional path to the geonames.org admin1CodesASCII.txt file
+	// for state and region names.
+	Admin1Path string
+
+	// Optional path to a binary index file. If it exists and was built from
+	// the same source files (by path, size, and modification time), locations
+	// are loaded from it instead of parsing the source files. Otherwise, it's
+	// (re)created after parsing them.
+	IndexPath string
 }
 
 // Location represents a geographic location.
@@ -32,153 +78,418 @@ type Location struct {
 	Country    string
 	Population int
 
+	// Admin1 (state, province, region) code and name.
+	Admin1 string
+	Region string
+
 	Loc *time.Location
 }
 
+// record is the compact, in-memory form of a Location. Strings that repeat
+// across locations are interned and referred to by index.
+type record struct {
+	ID      uint32
+	Name    string
+	Lat     float32
+	Lon     float32
+	Pop     uint32
+	Zone    uint16
+	Country uint16
+	Admin1  uint16
+}
+
+// Place returns the location's region and country, eg: "Oregon, US", or
+// just the country if the region is unknown.
+func (l Location) Place() string {
+	if l.Region == "" {
+		return l.Country
+	}
+
+	return l.Region + ", " + l.Country
+}
+
 var (
 	reClean = regexp.MustCompile("[^a-z/]+")
+
+	// Region names and admin1 codes, which can be numeric, eg: in/40.
+	reRegion = regexp.MustCompile("[^a-z0-9]+")
 )
 
 // New initiates a new geo location map.
-func New(filePath string) (*Geo, error) {
+func New(o Opt) (*Geo, error) {
 	g := &Geo{
-		tzMap: make(map[string][]Location),
+		tzMap:    make(map[string][]int32),
+		aliasMap: make(map[string][]int32),
+		admin1:   make(map[string]string),
 	}
 
-	locs, err := g.readFile(filePath)
+	// The source files are stat'ed before they're read so that changes
+	// while reading them rebuild the index the next time.
+	sources, err := statSources(o.FilePath, o.Admin1Path)
 	if err != nil {
 		return nil, err
 	}
 
-	g.load(locs)
+	// Load the precomputed index if it was built from the same sources.
+	if o.IndexPath != "" {
+		err := g.readIndex(o.IndexPath, sources)
+		if err == nil {
+			g.load(nil)
+			return g, nil
+		}
+
+		if !os.IsNotExist(err) {
+			log.Printf("error reading geo index %s, rebuilding: %v", o.IndexPath, err)
+		}
+	}
+
+	if o.Admin1Path != "" {
+		if err := g.readAdmin1File(o.Admin1Path); err != nil {
+			return nil, fmt.Errorf("error reading admin1 codes: %v", err)
+		}
+	}
+
+	aliases, err := g.readFile(o.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	g.load(aliases)
+
+	if o.IndexPath != "" {
+		if err := g.writeIndex(o.IndexPath, sources); err != nil {
+			log.Printf("error writing geo index %s: %v", o.IndexPath, err)
+		}
+	}
+
 	return g, nil
 }
 
-// Query queries a loaded geo location by the given keyword.
+// Query queries a loaded geo location by the given keyword. The keyword
+// can be suffixed by a country code and an admin1 (state, region) code or
+// name to narrow down the results, eg: london/gb, portland/us/or,
+// hyderabad/in/telangana.
 func (g *Geo) Query(q string) []Location {
-	// If there's a country code, separate it.
+	// If there's a country code, and a region, separate them.
 	var (
 		str     = strings.Split(q, "/")
 		country = ""
+		region  = ""
 	)
-	if len(str) == 2 && len(str[1]) == 2 {
+	if (len(str) == 2 || len(str) == 3) && len(str[1]) == 2 {
 		q = str[0]
 		country = strings.ToUpper(str[1])
+
+		if len(str) == 3 {
+			region = reRegion.ReplaceAllString(strings.ToLower(str[2]), "")
+		}
 	}
 
 	q = reClean.ReplaceAllString(strings.ToLower(q), "")
-	zones, ok := g.tzMap[q]
+	ids, ok := g.tzMap[q]
 	if !ok {
-		return nil
+		// Try alternate names.
+		ids, ok = g.aliasMap[q]
+		if !ok {
+			return nil
+		}
 	}
 
-	// Filter by country.
-	if country != "" {
-		out := make([]Location, 0, len(zones))
-		for _, z := range zones {
-			if z.Country != country {
-				continue
-			}
+	// Filter by country and region.
+	out := make([]Location, 0, len(ids))
+	for _, id := range ids {
+		l := g.location(id)
+		if country != "" && l.Country != country {
+			continue
+		}
 
-			out = append(out, z)
+		if region != "" && strings.ToLower(l.Admin1) != region &&
+			reRegion.ReplaceAllString(strings.ToLower(l.Region), "") != region {
+			continue
 		}
 
-		return out
+		out = append(out, l)
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+
+	return out
+}
+
+// NotFound returns an "unknown city" error for a query that has no matches,
+// suggesting the closest matching names, if any.
+func (g *Geo) NotFound(q string) error {
+	if s := g.Suggest(q); len(s) > 0 {
+		return fmt.Errorf("unknown city. Did you mean %s?", strings.Join(s, ", "))
 	}
 
-	return zones
+	return errors.New("unknown city.")
 }
 
 // Count returns the number of unique locations loaded.
 func (g *Geo) Count() int {
-	return g.count
+	return len(g.locations)
 }
 
-func (g *Geo) load(locs []Location) {
-	for _, l := range locs {
-		// Add the city name.
-		name := reClean.ReplaceAllString(strings.ToLower(l.Name), "")
+// Zone returns the IANA timezone name and location for the given name
+// matched case insensitively and ignoring underscores, eg: asia/kolkata,
+// america/newyork. Only the zones of the loaded locations are known.
+func (g *Geo) Zone(name string) (string, *time.Location, bool) {
+	i, ok := g.zoneMap[normalizeZone(name)]
+	if !ok {
+		return "", nil, false
+	}
+
+	return g.zones[i], g.zoneLocs[i], true
+}
+
+// location returns the Location for a record.
+func (g *Geo) location(id int32) Location {
+	var (
+		r  = g.locations[id]
+		cc = g.countries[r.Country]
+		a1 = g.admin1Codes[r.Admin1]
+	)
+
+	return Location{
+		ID:         strconv.FormatUint(uint64(r.ID), 10),
+		Name:       r.Name,
+		Lat:        float64(r.Lat),
+		Lon:        float64(r.Lon),
+		Timezone:   g.zones[r.Zone],
+		Country:    cc,
+		Population: int(r.Pop),
+		Admin1:     a1,
+		Region:     g.admin1[cc+"."+a1],
+		Loc:        g.zoneLocs[r.Zone],
+	}
+}
 
-		if _, ok := g.tzMap[name]; !ok {
-			g.tzMap[name] = []Location{}
+// load builds the name maps and the spatial index from the loaded records.
+// aliases are the alternate names of each record. They're nil when loading
+// from an index, which already has the alias map.
+func (g *Geo) load(aliases [][]string) {
+	// Resolve the interned timezones (when loading from an index).
+	if len(g.zoneLocs) != len(g.zones) {
+		g.zoneLocs = make([]*time.Location, len(g.zones))
+		for i, z := range g.zones {
+			loc, err := time.LoadLocation(z)
+			if err != nil {
+				loc = time.UTC
+			}
+			g.zoneLocs[i] = loc
 		}
+	}
 
-		g.tzMap[name] = append(g.tzMap[name], l)
+	g.zoneMap = make(map[string]int, len(g.zones))
+	for i, z := range g.zones {
+		g.zoneMap[normalizeZone(z)] = i
+	}
 
-		g.count++
+	for i, r := range g.locations {
+		// Add the city name.
+		name := reClean.ReplaceAllString(strings.ToLower(r.Name), "")
+		g.tzMap[name] = append(g.tzMap[name], int32(i))
 	}
 
 	// Cities in timezone names that don't exist in the map, add to the map.
-	for _, l := range locs {
-		city := reClean.ReplaceAllString(strings.Split(l.Timezone, "/")[1], "")
+	for i, r := range g.locations {
+		city := reClean.ReplaceAllString(strings.ToLower(strings.Split(g.zones[r.Zone], "/")[1]), "")
 		_, ok := g.tzMap[city]
 		if !ok {
-			g.tzMap[city] = []Location{l}
+			g.tzMap[city] = []int32{int32(i)}
+		}
+	}
+
+	// Index alternate names that aren't already primary names.
+	for i, names := range aliases {
+		seen := make(map[string]bool, len(names))
+		for _, a := range names {
+			name := reClean.ReplaceAllString(strings.ToLower(a), "")
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			if _, ok := g.tzMap[name]; ok {
+				continue
+			}
+
+			g.aliasMap[name] = append(g.aliasMap[name], int32(i))
 		}
 	}
 
 	// Sort cities in the collated map by population under the assumption
 	// that bigger cities are likely to be searched more.
-	for _, locs := range g.tzMap {
-		sort.Slice(locs, func(i, j int) bool {
-			return locs[i].Population > locs[j].Population
+	byPop := func(ids []int32) {
+		sort.SliceStable(ids, func(i, j int) bool {
+			return g.locations[ids[i]].Pop > g.locations[ids[j]].Pop
 		})
 	}
+	for _, ids := range g.tzMap {
+		byPop(ids)
+	}
+	if aliases != nil {
+		for _, ids := range g.aliasMap {
+			byPop(ids)
+		}
+	}
+
+	g.buildGrid()
+	g.buildSuggest()
 }
 
-// readFile loads a geonames.org geolocation file and returns the list
-// of parses Locations.
-func (g *Geo) readFile(filePath string) ([]Location, error) {
+// readFile loads a geonames.org geolocation file into the list of records
+// and returns the alternate names of each record.
+func (g *Geo) readFile(filePath string) ([][]string, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	rd := csv.NewReader(f)
-	rd.Comma = '\t'
+	// The files aren't CSV compliant (names may have stray quotes), so
+	// split the lines by tabs. Lines with long alternate names can be
+	// bigger than the scanner's default buffer.
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
 
-	out := []Location{}
-	for {
-		r, err := rd.Read()
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
+	var (
+		aliases = [][]string{}
 
-			return nil, err
+		// Interned strings by value.
+		names     = make(map[string]string)
+		zones     = make(map[string]int)
+		countries = make(map[string]int)
+		admin1    = make(map[string]int)
+	)
+	intern := func(s string) string {
+		if v, ok := names[s]; ok {
+			return v
+		}
+		s = strings.Clone(s)
+		names[s] = s
+		return s
+	}
+	internID := func(m map[string]int, list *[]string, s string) uint16 {
+		if id, ok := m[s]; ok {
+			return uint16(id)
 		}
+		m[s] = len(*list)
+		*list = append(*list, strings.Clone(s))
+		return uint16(m[s])
+	}
 
-		if len(r) != 19 {
+	for sc.Scan() {
+		r := strings.Split(sc.Text(), "\t")
+
+		// Only populated places.
+		if len(r) != 19 || r[6] != "P" {
 			continue
 		}
 
 		// Create the location record.
 		var (
+			id, _  = strconv.ParseUint(r[0], 10, 32)
 			lat, _ = strconv.ParseFloat(r[4], 32)
 			lon, _ = strconv.ParseFloat(r[5], 32)
-			pop, _ = strconv.Atoi(r[14])
+			pop, _ = strconv.ParseUint(r[14], 10, 32)
 		)
 
 		// Remove values in brackets.
 		r[2] = strings.TrimSpace(strings.Split(r[2], "(")[0])
 
-		loc, err := time.LoadLocation(r[17])
-		if err != nil {
+		// Load each timezone once.
+		zone, ok := zones[r[17]]
+		if !ok {
+			loc, err := time.LoadLocation(r[17])
+			if err != nil || !strings.Contains(r[17], "/") {
+				zones[r[17]] = -1
+				continue
+			}
+
+			zone = len(g.zones)
+			zones[r[17]] = zone
+			g.zones = append(g.zones, strings.Clone(r[17]))
+			g.zoneLocs = append(g.zoneLocs, loc)
+		}
+		if zone < 0 {
 			continue
 		}
 
-		out = append(out, Location{
-			ID:         r[0],
-			Name:       r[2],
-			Lat:        lat,
-			Lon:        lon,
-			Country:    r[8],
-			Timezone:   r[17],
-			Population: pop,
-			Loc:        loc,
+		// The native name and the comma separated alternate names. Only
+		// ASCII names are indexed as the keywords are reduced to a-z.
+		var alt []string
+		for _, a := range append([]string{r[1]}, strings.Split(r[3], ",")...) {
+			if a != "" && a != r[2] && isASCII(a) {
+				alt = append(alt, intern(a))
+			}
+		}
+		aliases = append(aliases, alt)
+
+		g.locations = append(g.locations, record{
+			ID:      uint32(id),
+			Name:    intern(r[2]),
+			Lat:     float32(lat),
+			Lon:     float32(lon),
+			Pop:     uint32(pop),
+			Zone:    uint16(zone),
+			Country: internID(countries, &g.countries, r[8]),
+			Admin1:  internID(admin1, &g.admin1Codes, r[10]),
 		})
 	}
 
-	return out, nil
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
+
+// readAdmin1File loads a geonames.org admin1CodesASCII.txt file which has
+// tab separated rows of $country.$admin1_code, name, ASCII name, geonameid.
+func (g *Geo) readAdmin1File(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rd := csv.NewReader(f)
+	rd.Comma = '\t'
+	rd.FieldsPerRecord = -1
+	rd.LazyQuotes = true
+
+	for {
+		r, err := rd.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return err
+		}
+
+		if len(r) < 3 {
+			continue
+		}
+
+		g.admin1[r[0]] = r[2]
+	}
+
+	return nil
+}
+
+func normalizeZone(z string) string {
+	return strings.NewReplacer("_", "", " ", "").Replace(strings.ToLower(z))
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,381 @@
+package geo
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Rows in the geonames.org cities format (19 tab separated columns).
+var fixture = [][]string{
+	{"2950096", "Berlin Hbf", "Berlin Hbf", "", "52.525", "13.369", "S", "RSTN", "DE", "", "16", "", "", "", "0", "", "35", "Europe/Berlin", "2020-01-01"},
+	{"2950159", "Berlin", "Berlin", "Berlin,Berlim,Berlino,Berlín", "52.52437", "13.41053", "P", "PPLC", "DE", "", "16", "00", "11000", "11000000", "3426354", "", "74", "Europe/Berlin", "2022-03-09"},
+	{"2661552", "Bern", "Bern", "Berna,Berne", "46.94809", "7.44744", "P", "PPLA", "CH", "", "BE", "246", "351", "0351", "121631", "", "549", "Europe/Zurich", "2019-09-05"},
+	{"1277333", "Bengaluru", "Bengaluru", "Bangalore,Bengaluru,Bengalooru", "12.97194", "77.59369", "P", "PPLA", "IN", "", "19", "583", "", "", "8443675", "", "920", "Asia/Kolkata", "2022-03-10"},
+	{"5128581", "New York City", "New York City", "New York,NYC,Nueva York,Нью-Йорк", "40.71427", "-74.00597", "P", "PPL", "US", "", "NY", "", "", "", "8804190", "10", "57", "America/New_York", "2024-03-26"},
+	{"2643743", "London", "London", "Londres,Londra", "51.50853", "-0.12574", "P", "PPLC", "GB", "", "ENG", "GLA", "", "", "8961989", "", "25", "Europe/London", "2023-01-12"},
+	{"6058560", "London", "London", "", "42.98339", "-81.23304", "P", "PPL", "CA", "", "08", "", "", "", "422324", "", "252", "America/Toronto", "2022-02-15"},
+	{"5746545", "Portland", "Portland", "", "45.52345", "-122.67621", "P", "PPLA2", "US", "", "OR", "051", "", "", "652503", "15", "61", "America/Los_Angeles", "2019-09-05"},
+	{"4984247", "Portland", "Portland", "", "43.66147", "-70.25533", "P", "PPLA2", "US", "", "ME", "005", "", "", "68408", "", "10", "America/New_York", "2017-05-23"},
+	{"1269843", "Hyderabad", "Hyderabad", "", "17.38405", "78.45636", "P", "PPLA", "IN", "", "40", "", "", "", "3597816", "", "536", "Asia/Kolkata", "2019-09-05"},
+	{"1176734", "Hyderabad", "Hyderabad", "", "25.39242", "68.37366", "P", "PPLA2", "PK", "", "05", "", "", "", "1386330", "", "24", "Asia/Karachi", "2019-12-06"},
+}
+
+var admin1Fixture = `US.OR	Oregon	Oregon	5744337
+US.ME	Maine	Maine	4971068
+IN.40	Telangana	Telangana	1254788
+PK.05	Sindh	Sindh	1164807
+GB.ENG	England	England	6269131
+`
+
+func newTestGeo(t *testing.T) *Geo {
+	t.Helper()
+
+	g, err := New(writeFixture(t))
+	if err != nil {
+		t.Fatalf("error loading fixture: %v", err)
+	}
+
+	return g
+}
+
+// writeFixture writes the fixtures to a temp dir and returns the options
+// to load them.
+func writeFixture(t *testing.T) Opt {
+	t.Helper()
+
+	var b strings.Builder
+	for _, r := range fixture {
+		b.WriteString(strings.Join(r, "\t") + "\n")
+	}
+
+	var (
+		dir        = t.TempDir()
+		path       = filepath.Join(dir, "cities.txt")
+		admin1Path = filepath.Join(dir, "admin1CodesASCII.txt")
+	)
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(admin1Path, []byte(admin1Fixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return Opt{FilePath: path, Admin1Path: admin1Path}
+}
+
+func TestQuery(t *testing.T) {
+	g := newTestGeo(t)
+
+	tests := []struct {
+		q     string
+		names []string
+	}{
+		{"berlin", []string{"Berlin"}},
+		{"london", []string{"London", "London"}},
+		{"london/ca", []string{"London"}},
+		{"newyork", []string{"New York City"}},
+		{"nyc", []string{"New York City"}},
+		{"bangalore", []string{"Bengaluru"}},
+		{"bengaluru", []string{"Bengaluru"}},
+		{"berlino", []string{"Berlin"}},
+		{"berln", nil},
+		{"london/xx", nil},
+		{"portland/us", []string{"Portland", "Portland"}},
+		{"portland/us/or", []string{"Portland"}},
+		{"portland/us/maine", []string{"Portland"}},
+		{"portland/us/ca", nil},
+		{"hyderabad/pk/sindh", []string{"Hyderabad"}},
+		{"hyderabad/in/telangana", []string{"Hyderabad"}},
+		{"hyderabad/in/40", []string{"Hyderabad"}},
+		{"hyderabad/pk/40", nil},
+		{"hyderabad/in/05", nil},
+	}
+	for _, tc := range tests {
+		locs := g.Query(tc.q)
+		if len(locs) != len(tc.names) {
+			t.Errorf("%s: want %d results got %d", tc.q, len(tc.names), len(locs))
+			continue
+		}
+		for i, l := range locs {
+			if l.Name != tc.names[i] {
+				t.Errorf("%s: want %s got %s", tc.q, tc.names[i], l.Name)
+			}
+		}
+	}
+
+	// Regions.
+	if l := g.Query("portland/us/maine"); l[0].Place() != "Maine, US" {
+		t.Errorf("unexpected place: %s", l[0].Place())
+	}
+	if l := g.Query("hyderabad/in/40"); l[0].Place() != "Telangana, IN" {
+		t.Errorf("unexpected place: %s", l[0].Place())
+	}
+	// The less populous city by its numeric admin1 code.
+	if l := g.Query("hyderabad/pk/05"); len(l) != 1 || l[0].Place() != "Sindh, PK" {
+		t.Errorf("unexpected places: %v", l)
+	}
+	if l := g.Query("london/ca"); l[0].Place() != "CA" {
+		t.Errorf("unexpected place: %s", l[0].Place())
+	}
+
+	// Bigger cities first.
+	if l := g.Query("london"); l[0].Country != "GB" {
+		t.Errorf("expected the bigger London first, got %s", l[0].Country)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	g := newTestGeo(t)
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"berln", []string{"berlin", "bern"}},
+		{"bangalor", []string{"bangalore"}},
+		{"londn/gb", []string{"london"}},
+		{"vondon", []string{"london"}},
+		{"xyz", nil},
+		{"qwertyuiop", nil},
+	}
+	for _, tc := range tests {
+		got := g.Suggest(tc.q)
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: want %v got %v", tc.q, tc.want, got)
+		}
+	}
+
+	if err := g.NotFound("berln"); err.Error() != "unknown city. Did you mean berlin, bern?" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := g.NotFound("xyz"); err.Error() != "unknown city." {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"berln", "berlin", 2, 1},
+		{"kitten", "sitting", 3, 3},
+		{"same", "same", 1, 0},
+		{"abcdef", "uvwxyz", 2, 3},
+	} {
+		if d := distance(c.a, c.b, c.max, nil); d != c.want {
+			t.Errorf("%s-%s: want %d got %d", c.a, c.b, c.want, d)
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	g := newTestGeo(t)
+
+	tests := []struct {
+		lat, lon float64
+		names    []string
+	}{
+		{12.97, 77.59, []string{"Bengaluru", "Hyderabad"}},
+		{52.0, 13.0, []string{"Berlin", "Bern"}},
+		{45.5, -122.6, []string{"Portland", "London"}},
+		{-80, 170, []string{"Bengaluru", "Hyderabad"}},
+	}
+	for _, tc := range tests {
+		n := g.Nearest(tc.lat, tc.lon, len(tc.names))
+		if len(n) != len(tc.names) {
+			t.Errorf("%v,%v: want %d results got %d", tc.lat, tc.lon, len(tc.names), len(n))
+			continue
+		}
+		for i, l := range n {
+			if l.Name != tc.names[i] {
+				t.Errorf("%v,%v: want %s got %s", tc.lat, tc.lon, tc.names[i], l.Name)
+			}
+		}
+	}
+
+	// The results should match a brute force scan.
+	for _, c := range [][2]float64{{0, 0}, {89, 0}, {-60, -179.5}, {40, 179.9}, {51.5, -0.1}} {
+		want := make([]Near, 0, len(g.locations))
+		for i := range g.locations {
+			l := g.location(int32(i))
+			want = append(want, Near{Location: l, Distance: Distance(c[0], c[1], l.Lat, l.Lon)})
+		}
+		sort.Slice(want, func(i, j int) bool {
+			return want[i].Distance < want[j].Distance
+		})
+
+		got := g.Nearest(c[0], c[1], 3)
+		for i := range got {
+			if got[i].ID != want[i].ID {
+				t.Errorf("%v: want %s got %s at %d", c, want[i].Name, got[i].Name, i)
+			}
+		}
+	}
+
+	if n := g.Nearest(12.97, 77.59, 1); n[0].String() != "Bengaluru, IN (0.5 KM)" {
+		t.Errorf("unexpected string: %s", n[0].String())
+	}
+	if d := Distance(51.50853, -0.12574, 52.52437, 13.41053); d < 930 || d > 932 {
+		t.Errorf("unexpected London-Berlin distance: %v", d)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	o := writeFixture(t)
+	o.IndexPath = filepath.Join(t.TempDir(), "geo.idx")
+
+	// An index built without the admin1 file is rebuilt when it's set,
+	// even if the file is older than the index.
+	admin1Path := o.Admin1Path
+	o.Admin1Path = ""
+	if _, err := New(o); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(admin1Path, old, old)
+	o.Admin1Path = admin1Path
+
+	g, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(o.IndexPath); err != nil {
+		t.Fatalf("index not written: %v", err)
+	}
+
+	// Non populated places (Berlin Hbf) are skipped and strings are interned.
+	if g.Count() != len(fixture)-1 || len(g.zones) != 8 || len(g.countries) != 7 {
+		t.Errorf("unexpected counts: %d %d %d", g.Count(), len(g.zones), len(g.countries))
+	}
+
+	if l := g.Query("hyderabad/in/telangana"); len(l) != 1 || l[0].Place() != "Telangana, IN" {
+		t.Errorf("unexpected locations from a rebuilt index: %v", l)
+	}
+
+	// A changed source file rebuilds the index.
+	b, _ := os.ReadFile(o.FilePath)
+	os.WriteFile(o.FilePath, append(b, []byte(strings.Join(fixture[1], "\t")+"\n")...), 0644)
+	os.Chtimes(o.FilePath, old, old)
+	if g, err := New(o); err != nil || len(g.Query("berlin")) != 2 {
+		t.Errorf("expected a rebuilt index with the changed source file: %v", err)
+	}
+	os.WriteFile(o.FilePath, b, 0644)
+	if g, err = New(o); err != nil {
+		t.Fatal(err)
+	}
+
+	// Load from the index without the source files.
+	os.Remove(o.FilePath)
+	os.Remove(o.Admin1Path)
+	ig, err := New(o)
+	if err != nil {
+		t.Fatalf("error loading index: %v", err)
+	}
+
+	for _, q := range []string{"berlin", "portland/us/maine", "bangalore", "kolkata"} {
+		a, b := g.Query(q), ig.Query(q)
+		if len(a) == 0 || len(a) != len(b) {
+			t.Errorf("%s: mismatch %d != %d", q, len(a), len(b))
+			continue
+		}
+		if a[0].ID != b[0].ID || a[0].Place() != b[0].Place() || a[0].Loc.String() != b[0].Loc.String() {
+			t.Errorf("%s: mismatch %v != %v", q, a[0], b[0])
+		}
+	}
+	if n := ig.Nearest(12.97, 77.59, 1); len(n) != 1 || n[0].Name != "Bengaluru" {
+		t.Errorf("unexpected nearest from index: %v", n)
+	}
+
+	// A corrupt index is rebuilt from the source files.
+	os.WriteFile(o.IndexPath, []byte("junk"), 0644)
+	if _, err := New(o); err == nil {
+		t.Errorf("expected error with a corrupt index and no source files")
+	}
+}
+
+// BenchmarkSuggest looks up misspelt and junk names in a large
+// synthetic dataset with three names per location.
+func BenchmarkSuggest(b *testing.B) {
+	const rows = 200000
+
+	var (
+		rnd  = rand.New(rand.NewSource(1))
+		word = func() string {
+			w := make([]byte, 4+rnd.Intn(10))
+			for i := range w {
+				w[i] = byte('a' + rnd.Intn(26))
+			}
+			return string(w)
+		}
+		buf strings.Builder
+	)
+	for i := 0; i < rows; i++ {
+		r := append([]string(nil), fixture[1]...)
+		r[0] = strconv.Itoa(i + 1)
+		r[1] = word()
+		r[2] = word()
+		r[3] = word() + "," + word()
+		r[14] = strconv.Itoa(rnd.Intn(1000000))
+		buf.WriteString(strings.Join(r, "\t") + "\n")
+	}
+
+	path := filepath.Join(b.TempDir(), "cities.txt")
+	if err := os.WriteFile(path, []byte(buf.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	g, err := New(Opt{FilePath: path})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	queries := []string{"xxxxxxx", "qwertyuiopas", g.names[0] + "x", "z" + g.names[1][1:]}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Suggest(queries[i%len(queries)])
+	}
+}
+
+// TestNearestHighLat compares Nearest with a brute force scan over a dense
+// set of locations near the poles where degrees of longitude are short.
+func TestNearestHighLat(t *testing.T) {
+	g := &Geo{
+		zones:       []string{"UTC"},
+		zoneLocs:    []*time.Location{time.UTC},
+		countries:   []string{"AQ"},
+		admin1Codes: []string{""},
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		g.locations = append(g.locations, record{
+			ID:  uint32(i),
+			Lat: float32(60 + rnd.Float64()*30),
+			Lon: float32(rnd.Float64()*360 - 180),
+		})
+	}
+	g.buildGrid()
+
+	for i := 0; i < 200; i++ {
+		lat, lon := 70+rnd.Float64()*20, rnd.Float64()*360-180
+
+		var best float64 = math.MaxFloat64
+		for _, r := range g.locations {
+			best = math.Min(best, Distance(lat, lon, float64(r.Lat), float64(r.Lon)))
+		}
+
+		if n := g.Nearest(lat, lon, 1); len(n) != 1 || n[0].Distance > best {
+			t.Errorf("%v,%v: want %v got %v", lat, lon, best, n)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Package geotest loads geo.Geo from geonames.org rows for the tests of
+// the services that look up cities.
+package geotest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+// Rows in the geonames.org cities format (19 tab separated columns).
+const (
+	Berlin    = "2950159\tBerlin\tBerlin\t\t52.52437\t13.41053\tP\tPPLC\tDE\t\t16\t\t\t\t3426354\t\t74\tEurope/Berlin\t2022-03-09"
+	Bengaluru = "1277333\tBengaluru\tBengaluru\tBangalore\t12.97194\t77.59369\tP\tPPLA\tIN\t\t19\t\t\t\t8443675\t\t920\tAsia/Kolkata\t2022-03-10"
+	Tromso    = "3133880\tTromsø\tTromso\tTromso\t69.6489\t18.95508\tP\tPPLA\tNO\t\t54\t\t\t\t38980\t\t\tEurope/Oslo\t2022-01-01"
+)
+
+// New writes the rows to a temp file and loads them.
+func New(t testing.TB, rows ...string) *geo.Geo {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cities.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(rows, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := geo.New(geo.Opt{FilePath: path})
+	if err != nil {
+		t.Fatalf("error loading geo rows: %v", err)
+	}
+
+	return g
+}
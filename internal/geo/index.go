@@ -0,0 +1,170 @@
+package geo
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexVersion is bumped whenever the structure of indexFile changes so
+// that older index files are rebuilt.
+const indexVersion = 2
+
+// indexHeader is written before indexFile. It records the source files
+// that the index was built from so that a stale index can be detected
+// without decoding all of it.
+type indexHeader struct {
+	Version int
+
+	// The cities file and the admin1 file. The admin1 path is empty
+	// if the index was built without it.
+	Sources []indexSource
+}
+
+// indexSource is a source file of an index.
+type indexSource struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// indexFile is the precomputed binary index of parsed locations that's
+// loaded on startup instead of re-parsing the geonames.org source files.
+type indexFile struct {
+	Locations   []record
+	Zones       []string
+	Countries   []string
+	Admin1Codes []string
+	Admin1      map[string]string
+	Aliases     map[string][]int32
+}
+
+// readIndex loads the locations from a binary index file if it was built
+// from the given source files.
+func (g *Geo) readIndex(filePath string, sources []indexSource) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var (
+		dec = gob.NewDecoder(bufio.NewReader(f))
+		hdr indexHeader
+	)
+	if err := dec.Decode(&hdr); err != nil {
+		return err
+	}
+	if hdr.Version != indexVersion {
+		return fmt.Errorf("unsupported index version %d", hdr.Version)
+	}
+	if err := checkSources(hdr.Sources, sources); err != nil {
+		return err
+	}
+
+	var idx indexFile
+	if err := dec.Decode(&idx); err != nil {
+		return err
+	}
+
+	g.locations = idx.Locations
+	g.zones = idx.Zones
+	g.countries = idx.Countries
+	g.admin1Codes = idx.Admin1Codes
+	if idx.Admin1 != nil {
+		g.admin1 = idx.Admin1
+	}
+	if idx.Aliases != nil {
+		g.aliasMap = idx.Aliases
+	}
+
+	return nil
+}
+
+// writeIndex writes the loaded locations and the source files they were
+// read from to a binary index file. The file is written to a temporary file
+// first and renamed so that a partially written index is never read.
+func (g *Geo) writeIndex(filePath string, sources []indexSource) error {
+	f, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	idx := indexFile{
+		Locations:   g.locations,
+		Zones:       g.zones,
+		Countries:   g.countries,
+		Admin1Codes: g.admin1Codes,
+		Admin1:      g.admin1,
+		Aliases:     g.aliasMap,
+	}
+
+	var (
+		w   = bufio.NewWriter(f)
+		enc = gob.NewEncoder(w)
+	)
+	if err := enc.Encode(indexHeader{Version: indexVersion, Sources: sources}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := enc.Encode(idx); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filePath)
+}
+
+// statSources returns the paths, sizes, and modification times of the
+// source files. Files that don't exist have a size of -1.
+func statSources(paths ...string) ([]indexSource, error) {
+	out := make([]indexSource, 0, len(paths))
+	for _, p := range paths {
+		src := indexSource{Path: p, Size: -1}
+		if p != "" {
+			st, err := os.Stat(p)
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			if err == nil {
+				src.Size, src.ModTime = st.Size(), st.ModTime()
+			}
+		}
+
+		out = append(out, src)
+	}
+
+	return out, nil
+}
+
+// checkSources checks whether an index was built from the current source
+// files. Source files that don't exist are only checked by their path so
+// that an index can be deployed without them.
+func checkSources(built, cur []indexSource) error {
+	if len(built) != len(cur) {
+		return fmt.Errorf("index has %d source files, expected %d", len(built), len(cur))
+	}
+
+	for i, c := range cur {
+		b := built[i]
+		if b.Path != c.Path {
+			return fmt.Errorf("index was built from %q and not %q", b.Path, c.Path)
+		}
+		if c.Size >= 0 && (b.Size != c.Size || !b.ModTime.Equal(c.ModTime)) {
+			return fmt.Errorf("%s has changed since the index was built", c.Path)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Near is a location near a coordinate.
+type Near struct {
+	Location
+
+	// Distance from the coordinate in KM.
+	Distance float64
+}
+
+// String returns the location's name, place, and distance, eg:
+// "Bengaluru, Karnataka, IN (0.8 KM)".
+func (n Near) String() string {
+	return fmt.Sprintf("%s, %s (%.1f KM)", n.Name, n.Place(), n.Distance)
+}
+
+// The spatial index is a grid of 1 degree lat/lon cells, each holding
+// the locations that fall in it.
+const (
+	gridRows = 180
+	gridCols = 360
+
+	// earthRadius is the mean radius of the earth in KMs.
+	earthRadius = 6371.0088
+
+	// kmPerDeg is the length of a degree of latitude in KMs.
+	kmPerDeg = earthRadius * math.Pi / 180
+)
+
+// Nearest returns up to n locations nearest to the given coordinate,
+// closest first.
+func (g *Geo) Nearest(lat, lon float64, n int) []Near {
+	if n < 1 || len(g.locations) == 0 {
+		return nil
+	}
+
+	// Candidate locations by their index and distance.
+	type cand struct {
+		id   int32
+		dist float64
+	}
+
+	var (
+		row, col = cell(lat, lon)
+		cands    []cand
+	)
+	add := func(r, c int) {
+		for _, id := range g.grid[r*gridCols+((c%gridCols)+gridCols)%gridCols] {
+			l := g.locations[id]
+			cands = append(cands, cand{id: id, dist: Distance(lat, lon, float64(l.Lat), float64(l.Lon))})
+		}
+	}
+	sortCands := func() {
+		sort.Slice(cands, func(i, j int) bool {
+			if cands[i].dist != cands[j].dist {
+				return cands[i].dist < cands[j].dist
+			}
+			return g.locations[cands[i].id].Pop > g.locations[cands[j].id].Pop
+		})
+	}
+
+	// Scan rings of cells around the coordinate's cell, expanding until
+	// there are n locations and no location beyond the scanned rings can
+	// be closer than the n-th one.
+	for r := 0; r <= gridCols/2; r++ {
+		for rw := row - r; rw <= row+r; rw++ {
+			if rw < 0 || rw >= gridRows {
+				continue
+			}
+
+			// Top and bottom edges of the ring. Column offsets
+			// beyond [-180, 179] wrap around to already scanned cells.
+			if rw == row-r || rw == row+r {
+				for dc := max(-r, -gridCols/2); dc <= min(r, gridCols/2-1); dc++ {
+					add(rw, col+dc)
+				}
+				continue
+			}
+
+			// Left and right edges.
+			add(rw, col-r)
+			if r < gridCols/2 {
+				add(rw, col+r)
+			}
+		}
+
+		if len(cands) < n {
+			continue
+		}
+		sortCands()
+
+		// Locations outside the scanned rings are at least r degrees away
+		// in latitude or longitude. The closest point r degrees of longitude
+		// away is on that meridian, at the cross-track distance from the
+		// coordinate, which is never farther than r degrees of latitude.
+		bound := earthRadius * math.Asin(math.Sin(float64(min(r, 90))*math.Pi/180)*math.Cos(lat*math.Pi/180))
+		if cands[n-1].dist <= bound || len(cands) == len(g.locations) {
+			break
+		}
+	}
+
+	sortCands()
+	if len(cands) > n {
+		cands = cands[:n]
+	}
+
+	out := make([]Near, 0, len(cands))
+	for _, c := range cands {
+		out = append(out, Near{Location: g.location(c.id), Distance: c.dist})
+	}
+
+	return out
+}
+
+// Distance returns the great-circle (haversine) distance in KMs between
+// two coordinates.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	var (
+		p1   = lat1 * math.Pi / 180
+		p2   = lat2 * math.Pi / 180
+		dLat = (lat2 - lat1) * math.Pi / 180
+		dLon = (lon2 - lon1) * math.Pi / 180
+	)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(p1)*math.Cos(p2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// cell returns the grid row and column for a coordinate.
+func cell(lat, lon float64) (int, int) {
+	row := int(math.Floor(lat)) + gridRows/2
+	row = max(0, min(row, gridRows-1))
+
+	col := int(math.Floor(lon)) + gridCols/2
+	col = ((col % gridCols) + gridCols) % gridCols
+
+	return row, col
+}
+
+// buildGrid builds the spatial grid index of the loaded locations.
+func (g *Geo) buildGrid() {
+	g.grid = make([][]int32, gridRows*gridCols)
+	for i, l := range g.locations {
+		r, c := cell(float64(l.Lat), float64(l.Lon))
+		g.grid[r*gridCols+c] = append(g.grid[r*gridCols+c], int32(i))
+	}
+}
@@ -0,0 +1,195 @@
+package geo
+
+import (
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the max number of closest matches suggested for
+// an unknown query.
+const maxSuggestions = 3
+
+// suggestKey is a bucket of keywords of a length that start (or end)
+// with a char.
+type suggestKey struct {
+	len  int
+	char byte
+	last bool
+}
+
+// nameRef is a keyword in a suggestion bucket with the set of chars in it.
+type nameRef struct {
+	mask uint32
+	id   int32
+}
+
+// Suggest returns the names closest to the given query by edit distance.
+// The allowed distance is bounded by the length of the query. Only names
+// that share the first or the last char with the query are looked up.
+func (g *Geo) Suggest(q string) []string {
+	if i := strings.Index(q, "/"); i >= 0 {
+		q = q[:i]
+	}
+	q = reClean.ReplaceAllString(strings.ToLower(q), "")
+
+	var maxDist int
+	switch {
+	case len(q) < 4:
+		return nil
+	case len(q) < 6:
+		maxDist = 1
+	default:
+		maxDist = 2
+	}
+
+	type match struct {
+		name string
+		dist int
+		pop  uint32
+	}
+	var (
+		matches []match
+		qMask   = charMask(q)
+		buf     = make([]int, 2*(len(q)+maxDist+1))
+	)
+
+	check := func(r nameRef) {
+		// Every char in one and not in the other needs at least one edit.
+		if bits.OnesCount32(qMask&^r.mask) > maxDist || bits.OnesCount32(r.mask&^qMask) > maxDist {
+			return
+		}
+
+		name := g.names[r.id]
+		d := distance(q, name, maxDist, buf)
+		if d > maxDist {
+			return
+		}
+
+		ids, ok := g.tzMap[name]
+		if !ok {
+			ids = g.aliasMap[name]
+		}
+		matches = append(matches, match{name: name, dist: d, pop: g.locations[ids[0]].Pop})
+	}
+
+	for n := len(q) - maxDist; n <= len(q)+maxDist; n++ {
+		for _, r := range g.suggest[suggestKey{len: n, char: q[0]}] {
+			check(r)
+		}
+
+		// Names that start with the same char are already checked above.
+		for _, r := range g.suggest[suggestKey{len: n, char: q[len(q)-1], last: true}] {
+			if g.names[r.id][0] != q[0] {
+				check(r)
+			}
+		}
+	}
+
+	// Closest first, and then the most populous.
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		if matches[i].pop != matches[j].pop {
+			return matches[i].pop > matches[j].pop
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	out := make([]string, 0, maxSuggestions)
+	for _, m := range matches {
+		out = append(out, m.name)
+		if len(out) == maxSuggestions {
+			break
+		}
+	}
+
+	return out
+}
+
+// buildSuggest indexes the keywords in tzMap and aliasMap by their length
+// and their first and last chars so that suggestions only have to compute
+// the edit distance to a small number of names.
+func (g *Geo) buildSuggest() {
+	g.names = make([]string, 0, len(g.tzMap)+len(g.aliasMap))
+	g.suggest = make(map[suggestKey][]nameRef)
+
+	add := func(name string) {
+		if name == "" {
+			return
+		}
+
+		r := nameRef{mask: charMask(name), id: int32(len(g.names))}
+		g.names = append(g.names, name)
+
+		k := suggestKey{len: len(name), char: name[0]}
+		g.suggest[k] = append(g.suggest[k], r)
+
+		k = suggestKey{len: len(name), char: name[len(name)-1], last: true}
+		g.suggest[k] = append(g.suggest[k], r)
+	}
+
+	for name := range g.tzMap {
+		add(name)
+	}
+	for name := range g.aliasMap {
+		if _, ok := g.tzMap[name]; !ok {
+			add(name)
+		}
+	}
+}
+
+// charMask returns the set of chars (a-z and /) in a keyword as a bitmask.
+func charMask(s string) uint32 {
+	var m uint32
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= 'a' && c <= 'z' {
+			m |= 1 << (c - 'a')
+		} else {
+			m |= 1 << 26
+		}
+	}
+
+	return m
+}
+
+// distance returns the Levenshtein edit distance between a and b. It stops
+// early and returns max+1 once the distance is known to exceed max. buf is
+// used for the rows of the matrix if it has room for 2*(len(b)+1) ints.
+func distance(a, b string, max int, buf []int) int {
+	if len(buf) < 2*(len(b)+1) {
+		buf = make([]int, 2*(len(b)+1))
+	}
+
+	var (
+		prev = buf[:len(b)+1]
+		cur  = buf[len(b)+1 : 2*(len(b)+1)]
+	)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if cur[j] < rowMin {
+				rowMin = cur[j]
+			}
+		}
+
+		if rowMin > max {
+			return max + 1
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
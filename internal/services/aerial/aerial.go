@@ -6,9 +6,14 @@ import (
 	"math"
 	"regexp"
 	"strconv"
+
+	"github.com/knadh/dns.toys/internal/geo"
 )
 
-type Aerial struct{}
+type Aerial struct {
+	// Optional. Names the cities nearest to the points.
+	geo *geo.Geo
+}
 
 type Location struct {
 	Lat  float64
@@ -28,9 +33,9 @@ var (
 	reParse         = regexp.MustCompile("A" + latLongPair + separator + latLongPair)
 )
 
-// New returns a new instance of Aerial.
-func New() *Aerial {
-	return &Aerial{}
+// New returns a new instance of Aerial. g is optional.
+func New(g *geo.Geo) *Aerial {
+	return &Aerial{geo: g}
 }
 
 // Query returns the aerial distance in KMs between lat long pair.
@@ -64,6 +69,15 @@ func (a *Aerial) Query(q string) ([]string, error) {
 	result := "aerial distance = " + strconv.FormatFloat(d, 'f', 2, 64) + " KM"
 	r := fmt.Sprintf(`%s %d TXT "%s"`, q, TTL, result)
 
+	// Name the cities nearest to both the points.
+	if a.geo != nil {
+		for _, l := range []Location{l1, l2} {
+			if n := a.geo.Nearest(l.Lat, l.Long, 1); len(n) > 0 {
+				r += fmt.Sprintf(` "%v,%v near %s"`, l.Lat, l.Long, n[0])
+			}
+		}
+	}
+
 	return []string{r}, nil
 }
 
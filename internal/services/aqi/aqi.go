@@ -10,6 +10,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,11 +19,21 @@ import (
 )
 
 const (
-	apiURL = "https://air-quality-api.open-meteo.com/v1/air-quality?latitude=%f&longitude=%f&hourly=pm10,pm2_5&timezone=auto&forecast_days=2"
+	apiURL = "https://air-quality-api.open-meteo.com/v1/air-quality?latitude=%f&longitude=%f&hourly=pm10,pm2_5,ozone,nitrogen_dioxide,sulphur_dioxide,carbon_monoxide,dust,uv_index,alder_pollen,birch_pollen,grass_pollen,mugwort_pollen,olive_pollen,ragweed_pollen&timezone=auto&past_days=1&forecast_days=2"
 
 	apiRateLimit = 15
 
 	TTL = 3600
+
+	// noData marks a pollutant value that's unavailable for a location,
+	// eg: pollen outside Europe.
+	noData = -1
+)
+
+// Query modifiers that select the details in the answer.
+const (
+	modFull   = "full"
+	modPollen = "pollen"
 )
 
 type entry struct {
@@ -35,6 +46,20 @@ type entry struct {
 type forecast struct {
 	Time        time.Time
 	PM10, PM2_5 float32
+
+	// Concentrations averaged over the trailing 24 hours, which
+	// standards such as US EPA and NAQI use for PM.
+	PM10Avg, PM2_5Avg float32
+
+	// Gases and dust in µg/m³ and the UV index.
+	O3, NO2, SO2, CO, Dust, UV float32
+
+	// Pollen in grains/m³.
+	Pollen pollen
+}
+
+type pollen struct {
+	Alder, Birch, Grass, Mugwort, Olive, Ragweed float32
 }
 
 type AQI struct {
@@ -53,9 +78,22 @@ type AQI struct {
 
 type response struct {
 	Hourly struct {
-		Time  []string  `json:"time"`
-		PM10  []float32 `json:"pm10"`
-		PM2_5 []float32 `json:"pm2_5"`
+		Time  []string   `json:"time"`
+		PM10  []*float32 `json:"pm10"`
+		PM2_5 []*float32 `json:"pm2_5"`
+		O3    []*float32 `json:"ozone"`
+		NO2   []*float32 `json:"nitrogen_dioxide"`
+		SO2   []*float32 `json:"sulphur_dioxide"`
+		CO    []*float32 `json:"carbon_monoxide"`
+		Dust  []*float32 `json:"dust"`
+		UV    []*float32 `json:"uv_index"`
+
+		Alder   []*float32 `json:"alder_pollen"`
+		Birch   []*float32 `json:"birch_pollen"`
+		Grass   []*float32 `json:"grass_pollen"`
+		Mugwort []*float32 `json:"mugwort_pollen"`
+		Olive   []*float32 `json:"olive_pollen"`
+		Ragweed []*float32 `json:"ragweed_pollen"`
 	} `json:"hourly"`
 }
 
@@ -66,9 +104,17 @@ type Opt struct {
 	CacheTTL   time.Duration
 	ReqTimeout time.Duration
 	UserAgent  string
+
+	// Default AQI standard (epa, naqi, caqi) used when a query
+	// doesn't specify one.
+	Standard string
 }
 
 func New(o Opt, g *geo.Geo) *AQI {
+	if _, ok := getStandard(o.Standard); !ok {
+		o.Standard = StdEPA
+	}
+
 	a := &AQI{
 		data:       make(map[string]entry),
 		fetchQueue: make(chan geo.Location, 1000),
@@ -91,10 +137,33 @@ func New(o Opt, g *geo.Geo) *AQI {
 
 }
 
+// Query returns the AQI forecast for a city. Optional modifiers select the
+// standard and the details, eg: delhi.naqi, berlin/de.pollen, delhi.full.caqi.
 func (a *AQI) Query(q string) ([]string, error) {
-	locs := a.geo.Query(q)
+	var (
+		parts = strings.Split(q, ".")
+		city  = parts[0]
+		std   = a.opt.Standard
+		mode  = ""
+	)
+	for _, p := range parts[1:] {
+		if s, ok := getStandard(p); ok {
+			std = s
+			continue
+		}
+
+		switch p {
+		case modFull, modPollen:
+			mode = p
+		default:
+			return nil, fmt.Errorf("unknown aqi modifier. Use %s, %s or one of %s, %s, %s.",
+				modFull, modPollen, StdEPA, StdNAQI, StdCAQI)
+		}
+	}
+
+	locs := a.geo.Query(city)
 	if locs == nil {
-		return nil, errors.New("unknown city")
+		return nil, a.geo.NotFound(city)
 	}
 
 	out := make([]string, 0, len(locs)*3)
@@ -117,9 +186,25 @@ func (a *AQI) Query(q string) ([]string, error) {
 		}
 
 		for _, f := range data.Forecasts {
-			r := fmt.Sprintf("%s %d TXT \"%s (%s)\" \"PM10 = %.1f\" \"PM2.5 = %.1f\" \"%s\"",
-				q, TTL, l.Name, l.Country, f.PM10, f.PM2_5, f.Time.In(zone).Format("15:04, Mon"))
+			var r string
+			switch mode {
+			case modFull:
+				r = fmt.Sprintf("%s %d TXT \"%s (%s)\" \"%s\" \"PM10 = %.1f\" \"PM2.5 = %.1f\" %s \"%s\"",
+					q, TTL, l.Name, l.Place(), formatIndex(std, f), f.PM10, f.PM2_5, formatFull(f), f.Time.In(zone).Format("15:04, Mon"))
+			case modPollen:
+				r = fmt.Sprintf("%s %d TXT \"%s (%s)\" %s \"%s\"",
+					q, TTL, l.Name, l.Place(), formatPollen(f.Pollen), f.Time.In(zone).Format("15:04, Mon"))
+			default:
+				r = fmt.Sprintf("%s %d TXT \"%s (%s)\" \"%s\" \"PM10 = %.1f\" \"PM2.5 = %.1f\" \"%s\"",
+					q, TTL, l.Name, l.Place(), formatIndex(std, f), f.PM10, f.PM2_5, f.Time.In(zone).Format("15:04, Mon"))
+			}
 			out = append(out, r)
+
+			// The detailed answers are long. Only show the latest forecast
+			// so that the response fits in a UDP packet.
+			if mode != "" {
+				break
+			}
 		}
 
 		if n > 2 {
@@ -291,10 +376,40 @@ func (a *AQI) fetchAPI(lat, lon float64) (entry, error) {
 			continue
 		}
 
+		if i >= len(data.Hourly.PM10) || i >= len(data.Hourly.PM2_5) ||
+			data.Hourly.PM10[i] == nil || data.Hourly.PM2_5[i] == nil {
+			continue
+		}
+
+		// Average over the trailing 24 hours of the series. The API returns
+		// a day of past data, so even the first forecast has a full window.
+		var (
+			pm10Avg, _  = average(data.Hourly.PM10, i-23, i)
+			pm2_5Avg, _ = average(data.Hourly.PM2_5, i-23, i)
+		)
+
 		f := forecast{
-			Time:  t,
-			PM10:  data.Hourly.PM10[i],
-			PM2_5: data.Hourly.PM2_5[i],
+			Time:     t,
+			PM10:     *data.Hourly.PM10[i],
+			PM2_5:    *data.Hourly.PM2_5[i],
+			PM10Avg:  float32(pm10Avg),
+			PM2_5Avg: float32(pm2_5Avg),
+
+			O3:   value(data.Hourly.O3, i),
+			NO2:  value(data.Hourly.NO2, i),
+			SO2:  value(data.Hourly.SO2, i),
+			CO:   value(data.Hourly.CO, i),
+			Dust: value(data.Hourly.Dust, i),
+			UV:   value(data.Hourly.UV, i),
+
+			Pollen: pollen{
+				Alder:   value(data.Hourly.Alder, i),
+				Birch:   value(data.Hourly.Birch, i),
+				Grass:   value(data.Hourly.Grass, i),
+				Mugwort: value(data.Hourly.Mugwort, i),
+				Olive:   value(data.Hourly.Olive, i),
+				Ragweed: value(data.Hourly.Ragweed, i),
+			},
 		}
 
 		// Only pick up entries with with a certain gap.
@@ -314,3 +429,68 @@ func (a *AQI) fetchAPI(lat, lon float64) (entry, error) {
 
 	return out, nil
 }
+
+// formatIndex computes and formats the AQI of a forecast under the given
+// standard, picking the concentrations for the standard's averaging window.
+func formatIndex(std string, f forecast) string {
+	pm10, pm2_5 := f.PM10, f.PM2_5
+	if standards[std].Window > 1 {
+		pm10, pm2_5 = f.PM10Avg, f.PM2_5Avg
+	}
+
+	idx := computeIndex(std, float64(pm10), float64(pm2_5))
+	if !idx.Available {
+		return idx.Std + " = n/a"
+	}
+
+	return fmt.Sprintf("%s = %d, %s (%s)", idx.Std, idx.Value, idx.Category, idx.Dominant)
+}
+
+// formatFull formats the gas, dust and UV readings of a forecast as
+// TXT strings.
+func formatFull(f forecast) string {
+	return fmt.Sprintf("\"O3 = %s\" \"NO2 = %s\" \"SO2 = %s\" \"CO = %s\" \"Dust = %s\" \"UV = %s\"",
+		formatVal(f.O3), formatVal(f.NO2), formatVal(f.SO2), formatVal(f.CO), formatVal(f.Dust), formatVal(f.UV))
+}
+
+// formatPollen formats pollen counts (grains/m³) as TXT strings.
+func formatPollen(p pollen) string {
+	vals := []struct {
+		name string
+		val  float32
+	}{
+		{"Alder", p.Alder}, {"Birch", p.Birch}, {"Grass", p.Grass},
+		{"Mugwort", p.Mugwort}, {"Olive", p.Olive}, {"Ragweed", p.Ragweed},
+	}
+
+	out := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if v.val == noData {
+			continue
+		}
+		out = append(out, fmt.Sprintf("\"%s = %.0f\"", v.name, v.val))
+	}
+
+	if len(out) == 0 {
+		return "\"pollen data is unavailable for this region\""
+	}
+
+	return strings.Join(out, " ")
+}
+
+func formatVal(v float32) string {
+	if v == noData {
+		return "n/a"
+	}
+
+	return fmt.Sprintf("%.1f", v)
+}
+
+// value returns the value at index i of an hourly series, or noData.
+func value(vals []*float32, i int) float32 {
+	if i >= len(vals) || vals[i] == nil {
+		return noData
+	}
+
+	return *vals[i]
+}
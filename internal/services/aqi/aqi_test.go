@@ -0,0 +1,87 @@
+package aqi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/geo/geotest"
+)
+
+func newTestAQI(t *testing.T) *AQI {
+	t.Helper()
+
+	var (
+		a  = New(Opt{Standard: StdEPA}, geotest.New(t, geotest.Berlin, geotest.Bengaluru))
+		tm = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	// Berlin has every reading and Bengaluru has no pollen or gases.
+	a.data["2950159"] = entry{
+		Valid:     true,
+		ExpiresAt: time.Now().Add(time.Hour),
+		Forecasts: []forecast{{
+			Time: tm, PM10: 20, PM2_5: 5, PM10Avg: 20, PM2_5Avg: 5,
+			O3: 60.25, NO2: 12, SO2: 1.5, CO: 210, Dust: 0, UV: 3.2,
+			Pollen: pollen{Alder: 12.4, Birch: 0, Grass: 3, Mugwort: noData, Olive: noData, Ragweed: 1},
+		}},
+	}
+	a.data["1277333"] = entry{
+		Valid:     true,
+		ExpiresAt: time.Now().Add(time.Hour),
+		Forecasts: []forecast{{
+			Time: tm, PM10: 40, PM2_5: 12, PM10Avg: 40, PM2_5Avg: 12,
+			O3: noData, NO2: noData, SO2: noData, CO: noData, Dust: 4.5, UV: noData,
+			Pollen: pollen{Alder: noData, Birch: noData, Grass: noData, Mugwort: noData, Olive: noData, Ragweed: noData},
+		}},
+	}
+
+	return a
+}
+
+func TestQuery(t *testing.T) {
+	a := newTestAQI(t)
+
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"berlin", `berlin 3600 TXT "Berlin (DE)" "US AQI = 28, Good (PM2.5)" "PM10 = 20.0" "PM2.5 = 5.0" "13:00, Fri"`},
+		{"berlin.full", `berlin.full 3600 TXT "Berlin (DE)" "US AQI = 28, Good (PM2.5)" "PM10 = 20.0" "PM2.5 = 5.0" ` +
+			`"O3 = 60.2" "NO2 = 12.0" "SO2 = 1.5" "CO = 210.0" "Dust = 0.0" "UV = 3.2" "13:00, Fri"`},
+		{"berlin.pollen", `berlin.pollen 3600 TXT "Berlin (DE)" "Alder = 12" "Birch = 0" "Grass = 3" "Ragweed = 1" "13:00, Fri"`},
+		{"bengaluru.full.naqi", `bengaluru.full.naqi 3600 TXT "Bengaluru (IN)" "IN NAQI = 40, Good (PM10)" "PM10 = 40.0" "PM2.5 = 12.0" ` +
+			`"O3 = n/a" "NO2 = n/a" "SO2 = n/a" "CO = n/a" "Dust = 4.5" "UV = n/a" "17:30, Fri"`},
+		{"bengaluru.pollen", `bengaluru.pollen 3600 TXT "Bengaluru (IN)" "pollen data is unavailable for this region" "17:30, Fri"`},
+	}
+	for _, tc := range tests {
+		out, err := a.Query(tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.q, err)
+			continue
+		}
+		if len(out) != 1 || out[0] != tc.want {
+			t.Errorf("%s: want %s got %s", tc.q, tc.want, out)
+		}
+	}
+
+	for q, want := range map[string]string{
+		"berlin.hourly": "unknown aqi modifier",
+		"berln":         "unknown city",
+	} {
+		if _, err := a.Query(q); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: want error %q got %v", q, want, err)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	f := func(v float32) *float32 { return &v }
+	vals := []*float32{f(1.5), nil}
+
+	for i, want := range []float32{1.5, noData, noData} {
+		if v := value(vals, i); v != want {
+			t.Errorf("%d: want %v got %v", i, want, v)
+		}
+	}
+}
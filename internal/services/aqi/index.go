@@ -0,0 +1,260 @@
+package aqi
+
+import (
+	"math"
+	"strings"
+)
+
+// Supported AQI standards.
+const (
+	StdEPA  = "epa"
+	StdNAQI = "naqi"
+	StdCAQI = "caqi"
+)
+
+// breakpoint maps a pollutant concentration range (Clo-Chi) to an
+// index range (Ilo-Ihi).
+type breakpoint struct {
+	Clo, Chi float64
+	Ilo, Ihi float64
+}
+
+// standard represents an AQI standard with its per-pollutant breakpoint
+// tables and index categories.
+type standard struct {
+	Name string
+
+	// Averaging window (in hours) over which concentrations are averaged
+	// before they're mapped to the index.
+	Window int
+
+	// Decimal places concentrations are truncated to before lookups.
+	PM10Digits, PM2_5Digits int
+
+	PM10, PM2_5 []breakpoint
+
+	// Upper index bounds of each category in ascending order.
+	Categories []category
+
+	// If set, concentrations beyond the last breakpoint are extrapolated
+	// along its slope instead of being capped at the highest index.
+	Open bool
+}
+
+type category struct {
+	Max  float64
+	Name string
+}
+
+// index represents a computed AQI value.
+type index struct {
+	Value     int
+	Category  string
+	Dominant  string
+	Std       string
+	SubPM10   int
+	SubPM2_5  int
+	Available bool
+}
+
+var standards = map[string]standard{
+	// US EPA, with the 2024 PM2.5 revision. 24h averages.
+	StdEPA: {
+		Name:        "US AQI",
+		Window:      24,
+		PM10Digits:  0,
+		PM2_5Digits: 1,
+		PM2_5: []breakpoint{
+			{0, 9, 0, 50},
+			{9.1, 35.4, 51, 100},
+			{35.5, 55.4, 101, 150},
+			{55.5, 125.4, 151, 200},
+			{125.5, 225.4, 201, 300},
+			{225.5, 325.4, 301, 500},
+		},
+		PM10: []breakpoint{
+			{0, 54, 0, 50},
+			{55, 154, 51, 100},
+			{155, 254, 101, 150},
+			{255, 354, 151, 200},
+			{355, 424, 201, 300},
+			{425, 604, 301, 500},
+		},
+		Categories: []category{
+			{50, "Good"},
+			{100, "Moderate"},
+			{150, "Unhealthy for sensitive groups"},
+			{200, "Unhealthy"},
+			{300, "Very unhealthy"},
+			{math.Inf(1), "Hazardous"},
+		},
+	},
+
+	// Indian National AQI (CPCB). 24h averages.
+	StdNAQI: {
+		Name:        "IN NAQI",
+		Window:      24,
+		PM10Digits:  0,
+		PM2_5Digits: 0,
+		PM2_5: []breakpoint{
+			{0, 30, 0, 50},
+			{31, 60, 51, 100},
+			{61, 90, 101, 200},
+			{91, 120, 201, 300},
+			{121, 250, 301, 400},
+			{251, 380, 401, 500},
+		},
+		PM10: []breakpoint{
+			{0, 50, 0, 50},
+			{51, 100, 51, 100},
+			{101, 250, 101, 200},
+			{251, 350, 201, 300},
+			{351, 430, 301, 400},
+			{431, 510, 401, 500},
+		},
+		Categories: []category{
+			{50, "Good"},
+			{100, "Satisfactory"},
+			{200, "Moderate"},
+			{300, "Poor"},
+			{400, "Very poor"},
+			{math.Inf(1), "Severe"},
+		},
+	},
+
+	// European Common Air Quality Index (CAQI), hourly grid.
+	StdCAQI: {
+		Name:        "EU CAQI",
+		Window:      1,
+		PM10Digits:  0,
+		PM2_5Digits: 0,
+		PM2_5: []breakpoint{
+			{0, 15, 0, 25},
+			{15, 30, 25, 50},
+			{30, 55, 50, 75},
+			{55, 110, 75, 100},
+		},
+		PM10: []breakpoint{
+			{0, 25, 0, 25},
+			{25, 50, 25, 50},
+			{50, 90, 50, 75},
+			{90, 180, 75, 100},
+		},
+		Categories: []category{
+			{25, "Very low"},
+			{50, "Low"},
+			{75, "Medium"},
+			{100, "High"},
+			{math.Inf(1), "Very high"},
+		},
+		Open: true,
+	},
+}
+
+// computeIndex computes the overall AQI for the given averaged PM10 and
+// PM2.5 concentrations (µg/m³) under a standard. The overall index is the
+// highest of the pollutant sub-indices.
+func computeIndex(std string, pm10, pm2_5 float64) index {
+	s, ok := standards[std]
+	if !ok {
+		return index{}
+	}
+
+	var (
+		sub10, ok10 = s.subIndex(s.PM10, truncate(pm10, s.PM10Digits))
+		sub25, ok25 = s.subIndex(s.PM2_5, truncate(pm2_5, s.PM2_5Digits))
+	)
+	if !ok10 && !ok25 {
+		return index{Std: s.Name}
+	}
+
+	out := index{
+		Std:       s.Name,
+		SubPM10:   sub10,
+		SubPM2_5:  sub25,
+		Available: true,
+	}
+	if sub25 >= sub10 {
+		out.Value, out.Dominant = sub25, "PM2.5"
+	} else {
+		out.Value, out.Dominant = sub10, "PM10"
+	}
+
+	for _, c := range s.Categories {
+		if float64(out.Value) <= c.Max {
+			out.Category = c.Name
+			break
+		}
+	}
+
+	return out
+}
+
+// subIndex linearly interpolates a concentration into its index band.
+func (s standard) subIndex(bps []breakpoint, c float64) (int, bool) {
+	if c < 0 || math.IsNaN(c) || len(bps) == 0 {
+		return 0, false
+	}
+
+	for i, b := range bps {
+		// Concentrations that fall in the gap between two bands after
+		// truncation belong to the upper band.
+		if c > b.Chi && i < len(bps)-1 {
+			continue
+		}
+
+		if c > b.Chi {
+			if !s.Open {
+				return int(b.Ihi), true
+			}
+		}
+
+		if c < b.Clo {
+			c = b.Clo
+		}
+
+		v := (b.Ihi-b.Ilo)/(b.Chi-b.Clo)*(c-b.Clo) + b.Ilo
+		return int(math.Round(v)), true
+	}
+
+	return 0, false
+}
+
+// getStandard returns the standard key for a given name, and whether it's valid.
+func getStandard(name string) (string, bool) {
+	name = strings.ToLower(name)
+	_, ok := standards[name]
+	return name, ok
+}
+
+// truncate truncates f to the given number of decimal places.
+func truncate(f float64, digits int) float64 {
+	p := math.Pow10(digits)
+	return math.Trunc(f*p) / p
+}
+
+// average returns the mean of the valid values in vals[from:to+1]
+// and whether there were any.
+func average(vals []*float32, from, to int) (float64, bool) {
+	if from < 0 {
+		from = 0
+	}
+
+	var (
+		sum float64
+		n   int
+	)
+	for i := from; i <= to && i < len(vals); i++ {
+		if vals[i] == nil {
+			continue
+		}
+		sum += float64(*vals[i])
+		n++
+	}
+
+	if n == 0 {
+		return 0, false
+	}
+
+	return sum / float64(n), true
+}
@@ -0,0 +1,66 @@
+package aqi
+
+import (
+	"testing"
+)
+
+var indexTests = []struct {
+	std         string
+	pm10, pm2_5 float64
+	value       int
+	category    string
+	dominant    string
+}{
+	// US EPA.
+	{StdEPA, 20, 5, 28, "Good", "PM2.5"},
+	{StdEPA, 40, 12.0, 56, "Moderate", "PM2.5"},
+	{StdEPA, 160, 20, 103, "Unhealthy for sensitive groups", "PM10"},
+	{StdEPA, 100, 88.2, 174, "Unhealthy", "PM2.5"},
+	{StdEPA, 50, 9.05, 50, "Good", "PM2.5"},
+	{StdEPA, 900, 10, 500, "Hazardous", "PM10"},
+
+	// Indian NAQI.
+	{StdNAQI, 45, 20, 45, "Good", "PM10"},
+	{StdNAQI, 80, 30.7, 80, "Satisfactory", "PM10"},
+	{StdNAQI, 120, 75, 149, "Moderate", "PM2.5"},
+	{StdNAQI, 300, 100, 250, "Poor", "PM10"},
+	{StdNAQI, 200, 180, 346, "Very poor", "PM2.5"},
+	{StdNAQI, 600, 450, 500, "Severe", "PM2.5"},
+
+	// EU CAQI (hourly).
+	{StdCAQI, 10, 5, 10, "Very low", "PM10"},
+	{StdCAQI, 40, 20, 40, "Low", "PM10"},
+	{StdCAQI, 60, 40, 60, "Medium", "PM2.5"},
+	{StdCAQI, 150, 60, 92, "High", "PM10"},
+	{StdCAQI, 270, 50, 125, "Very high", "PM10"},
+}
+
+func TestComputeIndex(t *testing.T) {
+	for n, c := range indexTests {
+		idx := computeIndex(c.std, c.pm10, c.pm2_5)
+		if !idx.Available {
+			t.Errorf("fail %d: %s %v %v -> index unavailable", n, c.std, c.pm10, c.pm2_5)
+			continue
+		}
+
+		if idx.Value != c.value || idx.Category != c.category || idx.Dominant != c.dominant {
+			t.Errorf("fail %d: %s %v %v -> want %d %s %s got %d %s %s", n, c.std, c.pm10, c.pm2_5,
+				c.value, c.category, c.dominant, idx.Value, idx.Category, idx.Dominant)
+		}
+	}
+}
+
+func TestAverage(t *testing.T) {
+	f := func(v float32) *float32 { return &v }
+	vals := []*float32{f(10), nil, f(20), f(30), nil}
+
+	if v, ok := average(vals, -5, 2); !ok || v != 15 {
+		t.Errorf("want 15 got %v", v)
+	}
+	if v, ok := average(vals, 0, 10); !ok || v != 20 {
+		t.Errorf("want 20 got %v", v)
+	}
+	if _, ok := average(vals, 4, 4); ok {
+		t.Errorf("expected no average for empty window")
+	}
+}
@@ -0,0 +1,450 @@
+// Package asn looks up the origin autonomous system (AS) of IP addresses
+// from a local routing table dump, such as the iptoasn.com TSV, that's
+// indexed in a radix tree and reloaded periodically.
+package asn
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TTL is set to 1 hour (60*60=3600) as routing tables change slowly.
+const TTL = 3600
+
+// nameCleaner removes the characters in AS names that break TXT records.
+var nameCleaner = strings.NewReplacer(`"`, "", `\`, "")
+
+// Opt contains config options for ASN.
+type Opt struct {
+	// File is the path to the routing table dump. It's loaded on start and
+	// overwritten on every refresh from URL, or reloaded when it changes
+	// if there's no URL. It can be gzipped.
+	File string
+
+	// Optional. URL is fetched every RefreshInterval.
+	URL             string
+	RefreshInterval time.Duration
+	ReqTimeout      time.Duration
+}
+
+// AS is an autonomous system.
+type AS struct {
+	Number  uint32
+	Name    string
+	Country string
+
+	// Number of IPv4 and IPv6 routes originated by the AS. They're the
+	// prefixes in pyasn dumps and the address ranges in iptoasn dumps.
+	Routes4 int
+	Routes6 int
+}
+
+// details returns the AS number and its name and country, if known. pyasn
+// dumps don't have names or countries.
+func (as *AS) details() []string {
+	out := []string{fmt.Sprintf("AS%d", as.Number)}
+	if as.Name != "" {
+		out = append(out, as.Name)
+	}
+	if as.Country != "" {
+		out = append(out, as.Country)
+	}
+
+	return out
+}
+
+// route is a prefix and its origin AS.
+type route struct {
+	prefix netip.Prefix
+	as     *AS
+}
+
+// table is a routing table loaded from a dump.
+type table struct {
+	routes tree
+	asns   map[uint32]*AS
+
+	// Whether the routes are address ranges (iptoasn) and not prefixes.
+	ranges bool
+}
+
+type ASN struct {
+	tbl      *table
+	loadedAt time.Time
+	modAt    time.Time
+
+	lastErrAt time.Time
+	mut       sync.RWMutex
+
+	opt    Opt
+	client *http.Client
+}
+
+// New returns a new instance of ASN. If URL is set, the table is refreshed
+// in the background, and if not, the file is reloaded when it changes.
+func New(o Opt) (*ASN, error) {
+	if o.RefreshInterval == 0 {
+		o.RefreshInterval = 24 * time.Hour
+	}
+	if o.ReqTimeout == 0 {
+		o.ReqTimeout = 30 * time.Second
+	}
+
+	a := &ASN{
+		opt:    o,
+		client: &http.Client{Timeout: o.ReqTimeout},
+	}
+
+	if err := a.reload(); err != nil && (!os.IsNotExist(err) || o.URL == "") {
+		return nil, fmt.Errorf("error loading ASN file: %v", err)
+	}
+
+	go a.run()
+
+	return a, nil
+}
+
+// Query returns the origin AS and the covering prefix of an IP, eg: 8.8.8.8,
+// or the details of an AS, eg: AS13335.
+func (a *ASN) Query(q string) ([]string, error) {
+	a.mut.RLock()
+	tbl := a.tbl
+	a.mut.RUnlock()
+
+	if tbl == nil {
+		return nil, errors.New("asn data is not loaded yet.")
+	}
+
+	// AS number.
+	if len(q) > 2 && strings.EqualFold(q[:2], "as") {
+		n, err := strconv.ParseUint(q[2:], 10, 32)
+		if err != nil {
+			return nil, errors.New("invalid AS number.")
+		}
+
+		as, ok := tbl.asns[uint32(n)]
+		if !ok {
+			return nil, errors.New("unknown AS number.")
+		}
+
+		unit := "prefixes"
+		if tbl.ranges {
+			unit = "ranges"
+		}
+
+		txt := append(as.details(), fmt.Sprintf("%d %s (%d ipv4, %d ipv6)", as.Routes4+as.Routes6, unit, as.Routes4, as.Routes6))
+		return []string{fmt.Sprintf("%s %d TXT \"%s\"", q, TTL, strings.Join(txt, `" "`))}, nil
+	}
+
+	ip, err := netip.ParseAddr(q)
+	if err != nil {
+		return nil, errors.New("invalid IP or AS number.")
+	}
+
+	rt := tbl.routes.lookup(ip.Unmap())
+	if rt == nil {
+		return nil, errors.New("IP is not routed.")
+	}
+
+	txt := append(rt.as.details(), rt.prefix.String())
+	return []string{fmt.Sprintf("%s %d TXT \"%s\"", q, TTL, strings.Join(txt, `" "`))}, nil
+}
+
+// Dump is not implemented in this package.
+func (a *ASN) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// Health returns the status of the routing table.
+func (a *ASN) Health() []string {
+	a.mut.RLock()
+	defer a.mut.RUnlock()
+
+	if a.tbl == nil {
+		return []string{"status = unavailable"}
+	}
+
+	out := []string{
+		"status = ok",
+		fmt.Sprintf("prefixes = %d", a.tbl.routes.size),
+		fmt.Sprintf("asns = %d", len(a.tbl.asns)),
+		"last success = " + a.loadedAt.UTC().Format(time.RFC3339),
+	}
+	if a.lastErrAt.After(a.loadedAt) {
+		out = append(out, "last error = "+a.lastErrAt.UTC().Format(time.RFC3339))
+	}
+
+	return out
+}
+
+// run periodically fetches the table from URL, or reloads the file if it
+// has changed.
+func (a *ASN) run() {
+	for {
+		// Reload the local file if it has changed.
+		if a.opt.URL == "" {
+			time.Sleep(a.opt.RefreshInterval)
+			if err := a.reload(); err != nil {
+				log.Printf("error reloading ASN file: %v", err)
+
+				a.mut.Lock()
+				a.lastErrAt = time.Now()
+				a.mut.Unlock()
+			}
+			continue
+		}
+
+		if err := a.refresh(); err != nil {
+			log.Printf("error refreshing ASN data: %v", err)
+
+			a.mut.Lock()
+			a.lastErrAt = time.Now()
+			a.mut.Unlock()
+
+			// Fetch failed. Retry again in a few minutes.
+			time.Sleep(5 * time.Minute)
+			continue
+		}
+
+		time.Sleep(a.opt.RefreshInterval)
+	}
+}
+
+// reload loads the file if it has changed since it was last loaded.
+func (a *ASN) reload() error {
+	st, err := os.Stat(a.opt.File)
+	if err != nil {
+		return err
+	}
+
+	a.mut.RLock()
+	modAt := a.modAt
+	a.mut.RUnlock()
+	if st.ModTime().Equal(modAt) {
+		return nil
+	}
+
+	b, err := os.ReadFile(a.opt.File)
+	if err != nil {
+		return err
+	}
+	if err := a.load(b); err != nil {
+		return err
+	}
+
+	a.mut.Lock()
+	a.modAt = st.ModTime()
+	n := a.tbl.routes.size
+	a.mut.Unlock()
+	log.Printf("%d prefixes loaded from %s", n, a.opt.File)
+
+	return nil
+}
+
+// refresh fetches the table from URL, loads it, and saves it to File.
+func (a *ASN) refresh() error {
+	resp, err := a.client.Get(a.opt.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if err := a.load(b); err != nil {
+		return err
+	}
+
+	a.mut.RLock()
+	n := a.tbl.routes.size
+	a.mut.RUnlock()
+	log.Printf("%d prefixes loaded from %s", n, a.opt.URL)
+
+	// Write to a temp file and rename so that a crash doesn't leave a
+	// truncated file behind.
+	if a.opt.File == "" {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(a.opt.File), filepath.Base(a.opt.File)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), a.opt.File)
+}
+
+// load parses a routing table dump and replaces the table.
+func (a *ASN) load(b []byte) error {
+	var r io.Reader = bytes.NewReader(b)
+
+	// Gzipped dumps.
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tbl, err := parse(r)
+	if err != nil {
+		return err
+	}
+	if tbl.routes.size == 0 {
+		return errors.New("no prefixes found")
+	}
+
+	a.mut.Lock()
+	a.tbl = tbl
+	a.loadedAt = time.Now()
+	a.mut.Unlock()
+
+	return nil
+}
+
+// parse reads a routing table dump in either of these tab separated formats.
+//
+//	iptoasn: range_start range_end as_number country_code as_description
+//	pyasn:   prefix as_number
+//
+// Lines starting with ; or # are comments. Unrouted ranges with the AS
+// number 0 are skipped.
+func parse(r io.Reader) (*table, error) {
+	tbl := &table{asns: make(map[uint32]*AS)}
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		var (
+			cols     = strings.Split(line, "\t")
+			prefixes []netip.Prefix
+			asCol    = 1
+		)
+		if strings.Contains(cols[0], "/") {
+			p, err := netip.ParsePrefix(cols[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid prefix: %s", n, cols[0])
+			}
+			prefixes = []netip.Prefix{p.Masked()}
+		} else {
+			if len(cols) < 3 {
+				return nil, fmt.Errorf("line %d: expected at least 3 columns", n)
+			}
+
+			start, err := netip.ParseAddr(cols[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid IP: %s", n, cols[0])
+			}
+			end, err := netip.ParseAddr(cols[1])
+			if err != nil || start.Is4() != end.Is4() || start.Compare(end) > 0 {
+				return nil, fmt.Errorf("line %d: invalid IP range: %s - %s", n, cols[0], cols[1])
+			}
+			prefixes = rangePrefixes(start, end)
+			asCol = 2
+			tbl.ranges = true
+		}
+
+		if len(cols) <= asCol {
+			return nil, fmt.Errorf("line %d: missing AS number", n)
+		}
+		num, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(cols[asCol]), "AS"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid AS number: %s", n, cols[asCol])
+		}
+		if num == 0 {
+			continue
+		}
+
+		as, ok := tbl.asns[uint32(num)]
+		if !ok {
+			as = &AS{Number: uint32(num)}
+			tbl.asns[as.Number] = as
+		}
+		if len(cols) > asCol+2 {
+			as.Country = strings.TrimSpace(cols[asCol+1])
+			as.Name = nameCleaner.Replace(strings.TrimSpace(cols[asCol+2]))
+		}
+
+		// A range is counted once even though it's indexed as the prefixes
+		// that cover it.
+		for _, p := range prefixes {
+			tbl.routes.insert(p, &route{prefix: p, as: as})
+		}
+		if prefixes[0].Addr().Is4() {
+			as.Routes4++
+		} else {
+			as.Routes6++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return tbl, nil
+}
+
+// rangePrefixes returns the smallest list of prefixes that cover the IPs
+// from a to b.
+func rangePrefixes(a, b netip.Addr) []netip.Prefix {
+	var out []netip.Prefix
+	for a.IsValid() && a.Compare(b) <= 0 {
+		// The largest prefix that starts at a and ends within b.
+		var p netip.Prefix
+		for n := 0; n <= a.BitLen(); n++ {
+			p = netip.PrefixFrom(a, n)
+			if p.Masked().Addr() == a && lastAddr(p).Compare(b) <= 0 {
+				break
+			}
+		}
+
+		out = append(out, p)
+		a = lastAddr(p).Next()
+	}
+
+	return out
+}
+
+// lastAddr returns the last IP in a prefix.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+
+	ip, _ := netip.AddrFromSlice(b)
+	return ip
+}
@@ -0,0 +1,150 @@
+package asn
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const iptoasn = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n" +
+	"1.1.1.0\t1.1.1.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"8.8.4.0\t8.8.4.255\t15169\tUS\tGOOGLE\n" +
+	"8.8.8.0\t8.8.8.255\t15169\tUS\tGOOGLE\n" +
+	"10.0.0.1\t10.0.0.6\t64512\tZZ\tTEST \"RANGE\"\n" +
+	"2606:4700::\t2606:4700:ffff:ffff:ffff:ffff:ffff:ffff\t13335\tUS\tCLOUDFLARENET\n"
+
+func TestQuery(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(iptoasn))
+	gz.Close()
+
+	a := &ASN{}
+	if err := a.load(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"1.1.1.1", `1.1.1.1 3600 TXT "AS13335" "CLOUDFLARENET" "US" "1.1.1.0/24"`},
+		{"8.8.8.8", `8.8.8.8 3600 TXT "AS15169" "GOOGLE" "US" "8.8.8.0/24"`},
+		{"10.0.0.5", `10.0.0.5 3600 TXT "AS64512" "TEST RANGE" "ZZ" "10.0.0.4/31"`},
+		{"2606:4700::1111", `2606:4700::1111 3600 TXT "AS13335" "CLOUDFLARENET" "US" "2606:4700::/32"`},
+		{"::ffff:8.8.4.4", `::ffff:8.8.4.4 3600 TXT "AS15169" "GOOGLE" "US" "8.8.4.0/24"`},
+		{"AS13335", `AS13335 3600 TXT "AS13335" "CLOUDFLARENET" "US" "3 ranges (2 ipv4, 1 ipv6)"`},
+		{"AS64512", `AS64512 3600 TXT "AS64512" "TEST RANGE" "ZZ" "1 ranges (1 ipv4, 0 ipv6)"`},
+		{"as15169", `as15169 3600 TXT "AS15169" "GOOGLE" "US" "2 ranges (2 ipv4, 0 ipv6)"`},
+	}
+	for _, tc := range tests {
+		out, err := a.Query(tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.q, err)
+			continue
+		}
+		if !slices.Equal(out, []string{tc.want}) {
+			t.Errorf("%s: want %s got %s", tc.q, tc.want, out)
+		}
+		if _, err := dns.NewRR(out[0]); err != nil {
+			t.Errorf("%s: invalid RR: %v", out[0], err)
+		}
+	}
+
+	for _, q := range []string{"1.0.2.1", "9.9.9.9", "AS0", "AS64496", "ASX", "x"} {
+		if _, err := a.Query(q); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+}
+
+func TestPyASN(t *testing.T) {
+	tbl, err := parse(bytes.NewReader([]byte("; IP-ASN32-DAT file\n10.0.0.0/8\t64512\n10.1.0.0/16\t64513\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, want := range map[string]uint32{"10.0.0.1": 64512, "10.1.2.3": 64513, "10.2.0.1": 64512} {
+		r := tbl.routes.lookup(netip.MustParseAddr(ip))
+		if r == nil || r.as.Number != want {
+			t.Errorf("%s: want AS%d got %v", ip, want, r)
+		}
+	}
+
+	a := &ASN{tbl: tbl}
+	// pyasn dumps have no names or countries.
+	if out, _ := a.Query("AS64512"); len(out) != 1 || out[0] != `AS64512 3600 TXT "AS64512" "1 prefixes (1 ipv4, 0 ipv6)"` {
+		t.Errorf("unexpected AS: %v", out)
+	}
+	if out, _ := a.Query("10.1.2.3"); len(out) != 1 || out[0] != `10.1.2.3 3600 TXT "AS64513" "10.1.0.0/16"` {
+		t.Errorf("unexpected route: %v", out)
+	}
+}
+
+func TestTree(t *testing.T) {
+	var tr tree
+	for _, p := range []string{"0.0.0.0/0", "10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.128.0.0/9", "192.168.1.1/32", "2001:db8::/32", "10.1.0.0/16"} {
+		pf := netip.MustParsePrefix(p)
+		tr.insert(pf, &route{prefix: pf})
+	}
+	if tr.size != 7 {
+		t.Errorf("want 7 prefixes got %d", tr.size)
+	}
+
+	for ip, want := range map[string]string{
+		"10.1.2.3":      "10.1.2.0/24",
+		"10.1.3.3":      "10.1.0.0/16",
+		"10.2.0.1":      "10.0.0.0/8",
+		"10.200.0.1":    "10.128.0.0/9",
+		"192.168.1.1":   "192.168.1.1/32",
+		"192.168.1.2":   "0.0.0.0/0",
+		"2001:db8::1":   "2001:db8::/32",
+		"2001:db9::1":   "",
+		"172.16.0.1":    "0.0.0.0/0",
+		"255.255.255.0": "0.0.0.0/0",
+	} {
+		r := tr.lookup(netip.MustParseAddr(ip))
+		got := ""
+		if r != nil {
+			got = r.prefix.String()
+		}
+		if got != want {
+			t.Errorf("%s: want %s got %s", ip, want, got)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "asn.tsv")
+	if err := os.WriteFile(f, []byte(iptoasn), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(Opt{File: f, RefreshInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Query("9.9.9.9"); err == nil {
+		t.Fatal("9.9.9.9: expected error")
+	}
+
+	// The file changes.
+	if err := os.WriteFile(f, []byte("9.9.9.0\t9.9.9.255\t19281\tUS\tQUAD9-1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(f, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	if err := a.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := a.Query("9.9.9.9"); err != nil || len(out) != 1 {
+		t.Errorf("9.9.9.9: unexpected result after reload: %v %v", out, err)
+	}
+}
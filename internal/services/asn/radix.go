@@ -0,0 +1,131 @@
+package asn
+
+import (
+	"math/bits"
+	"net/netip"
+)
+
+// tree is a path compressed binary radix tree of prefixes for longest
+// prefix matches. IPv4 prefixes are stored as IPv4-mapped IPv6 prefixes
+// so that both families share the tree.
+type tree struct {
+	root *node
+	size int
+}
+
+// node is a prefix in the tree. Nodes that are only branch points between
+// two prefixes don't have a route.
+type node struct {
+	key   [16]byte
+	bits  int
+	route *route
+	child [2]*node
+}
+
+// insert adds a prefix to the tree, replacing its route if it exists.
+func (t *tree) insert(p netip.Prefix, r *route) {
+	key, n := treeKey(p)
+
+	next := &t.root
+	for {
+		cur := *next
+		if cur == nil {
+			*next = &node{key: key, bits: n, route: r}
+			t.size++
+			return
+		}
+
+		c := commonBits(key, cur.key, min(n, cur.bits))
+		switch {
+		// The prefix is in the node's subtree.
+		case c == cur.bits && n > cur.bits:
+			next = &cur.child[bit(key, cur.bits)]
+			continue
+
+		// The node is the prefix.
+		case c == cur.bits:
+			if cur.route == nil {
+				t.size++
+			}
+			cur.route = r
+
+		// The prefix is a parent of the node.
+		case c == n:
+			nd := &node{key: key, bits: n, route: r}
+			nd.child[bit(cur.key, n)] = cur
+			*next = nd
+			t.size++
+
+		// The prefix and the node diverge. Branch at their common bits.
+		default:
+			nd := &node{key: maskKey(key, c), bits: c}
+			nd.child[bit(cur.key, c)] = cur
+			nd.child[bit(key, c)] = &node{key: key, bits: n, route: r}
+			*next = nd
+			t.size++
+		}
+
+		return
+	}
+}
+
+// lookup returns the route of the longest prefix that contains an IP.
+func (t *tree) lookup(ip netip.Addr) *route {
+	var (
+		key = ip.As16()
+		out *route
+	)
+	for n := t.root; n != nil; {
+		if commonBits(key, n.key, n.bits) < n.bits {
+			break
+		}
+		if n.route != nil {
+			out = n.route
+		}
+		if n.bits == 128 {
+			break
+		}
+		n = n.child[bit(key, n.bits)]
+	}
+
+	return out
+}
+
+// treeKey returns the 16 byte key and its length in bits of a prefix.
+func treeKey(p netip.Prefix) ([16]byte, int) {
+	p = p.Masked()
+	if p.Addr().Is4() {
+		return p.Addr().As16(), p.Bits() + 96
+	}
+
+	return p.Addr().As16(), p.Bits()
+}
+
+// commonBits returns the number of leading bits, up to max, that a and b
+// have in common.
+func commonBits(a, b [16]byte, max int) int {
+	n := 0
+	for i := 0; i < 16 && n < max; i++ {
+		if x := a[i] ^ b[i]; x != 0 {
+			n += bits.LeadingZeros8(x)
+			break
+		}
+		n += 8
+	}
+
+	return min(n, max)
+}
+
+// bit returns the bit of a key at a position.
+func bit(key [16]byte, pos int) int {
+	return int(key[pos/8]>>(7-pos%8)) & 1
+}
+
+// maskKey returns a key with the bits after n cleared.
+func maskKey(key [16]byte, n int) [16]byte {
+	for i := n; i < 128; i++ {
+		key[i/8] &^= 1 << (7 - i%8)
+	}
+
+	return key
+}
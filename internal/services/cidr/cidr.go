@@ -5,7 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
-	"net"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 type CIDR struct{}
@@ -15,74 +19,356 @@ func New() *CIDR {
 	return &CIDR{}
 }
 
-// TTL is set to 900 seconds (15 minutes).
-const TTL = 900
+const (
+	// TTL is set to 900 seconds (15 minutes).
+	TTL = 900
+
+	// maxSubnets is the max number of subnets returned for a query.
+	maxSubnets = 8
+
+	// maxSize is the max size of a response in bytes. The server only
+	// responds over UDP without compression or truncation, so responses
+	// have to fit in 512 byte messages.
+	maxSize = 512
+
+	// maxPrefixes is the max number of prefixes in a list to aggregate.
+	maxPrefixes = 16
+)
+
+var reSplit = regexp.MustCompile(`^(.+)\.split([0-9]+)$`)
 
 // Query parses a given query string and returns the answer.
-// For the cidr package, the query is an IP Address Prefix (CIDR notation).
+// For the cidr package, the query is an IP Address Prefix (CIDR notation),
+// eg: 10.0.0.0/16. Other queries are:
+//
+//	10.0.0.0/16.split24            subnets of a prefix
+//	10.0.0.5-10.0.0.0/24           whether an IP or a prefix is in a prefix
+//	10.0.0.0-10.0.3.255            prefixes of an IP range
+//	10.0.0.0/24,10.0.1.0/24        aggregated prefixes and overlaps of a list
 func (c *CIDR) Query(q string) ([]string, error) {
-	ipAddr, network, err := net.ParseCIDR(q)
+	if strings.Contains(q, ",") {
+		return c.aggregate(q)
+	}
+
+	if res := reSplit.FindStringSubmatch(q); len(res) == 3 {
+		return c.split(q, res[1], res[2])
+	}
+
+	if a, b, ok := strings.Cut(q, "-"); ok {
+		if strings.Contains(b, "/") {
+			return c.contains(q, a, b)
+		}
+		return c.rangeToCIDR(q, a, b)
+	}
+
+	p, err := netip.ParsePrefix(q)
 	if err != nil {
 		return nil, errors.New("invalid cidr notation.")
 	}
-	prefixLen, bits := network.Mask.Size()
 
-	switch {
+	var (
+		bits    = p.Bits()
+		network = p.Masked().Addr()
+		last    = lastAddr(p)
+	)
+
 	// Handle ipv4.
-	case ipAddr.To4() != nil:
-		// Binary "AND" operation between the IP address and the subnet mask.
-		for i := range network.IP.To4() {
-			network.IP[i] &= network.Mask[i]
+	if p.Addr().Is4() {
+		first := network
+
+		// Ignore the first and last IPs as they're the base and broadcast
+		// IPs which are unusable. If /31 or /32, assume a point-to-point link
+		// and return the lower and upper addresses.
+		usable := last
+		if bits < 31 {
+			first = first.Next()
+			usable = usable.Prev()
+		}
+
+		// Get the size of subnet.
+		size := 1 << (32 - bits)
+
+		return []string{
+			fmt.Sprintf("%s %d TXT \"%s\" \"%s\" \"%d\"", q, TTL, first, usable, size),
+			fmt.Sprintf("%s %d TXT \"network %s\" \"netmask %s\" \"wildcard %s\" \"broadcast %s\"",
+				q, TTL, network, mask(p, false), mask(p, true), last),
+		}, nil
+	}
+
+	// Handle ipv6. uint64 won't suffice for IPv6 prefixes lesser than /65.
+	size := big.NewInt(1)
+	size = size.Lsh(size, uint(128-bits))
+
+	return []string{
+		fmt.Sprintf("%s %d TXT \"%s\" \"%s\" \"%d\"", q, TTL, network, last, size),
+		fmt.Sprintf("%s %d TXT \"network %s\" \"netmask %s\"", q, TTL, network, mask(p, false)),
+	}, nil
+}
+
+// Dump produces a gob dump of the cached data.
+func (c *CIDR) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// split returns the subnets of a prefix with a longer prefix length,
+// eg: 10.0.0.0/16.split24.
+func (c *CIDR) split(q, prefix, length string) ([]string, error) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return nil, errors.New("invalid cidr notation.")
+	}
+	p = p.Masked()
+
+	n, err := strconv.Atoi(length)
+	if err != nil || n < p.Bits() || n > p.Addr().BitLen() {
+		return nil, fmt.Errorf("invalid prefix length. should be between %d and %d.", p.Bits(), p.Addr().BitLen())
+	}
+
+	total := big.NewInt(1)
+	total = total.Lsh(total, uint(n-p.Bits()))
+
+	var (
+		head = fmt.Sprintf("%d subnets", total)
+		out  = []string{fmt.Sprintf("%s %d TXT \"%s\"", q, TTL, head)}
+		size = msgSize(q) + recordSize(q, head)
+	)
+	for s := netip.PrefixFrom(p.Addr(), n); len(out) <= maxSubnets; {
+		var (
+			sub = s.String()
+			rng = fmt.Sprintf("%s - %s", s.Addr(), lastAddr(s))
+		)
+		if size += recordSize(q, sub, rng); size > maxSize {
+			break
 		}
-		// Ignore the first IP as it's the base IP which is unusable.
-		// If /31 assume a point-to-point // link and return the lower address.
-		if prefixLen < 31 {
-			network.IP[3]++
+		out = append(out, fmt.Sprintf("%s %d TXT \"%s\" \"%s\"", q, TTL, sub, rng))
+
+		next := lastAddr(s).Next()
+		if !next.IsValid() || !p.Contains(next) {
+			break
 		}
-		first := network.IP.To4().String()
+		s = netip.PrefixFrom(next, n)
+	}
 
-		// Binary "OR" operation on the IP with the bitwise binary inverse of the subnet mask to the first IP address.
-		for i := range network.IP.To4() {
-			network.IP[i] |= ^network.Mask[i]
+	return out, nil
+}
+
+// contains returns whether an IP or a prefix is within a prefix,
+// eg: 10.0.0.5-10.0.0.0/24.
+func (c *CIDR) contains(q, in, prefix string) ([]string, error) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return nil, errors.New("invalid cidr notation.")
+	}
+	p = p.Masked()
+
+	var ok bool
+	if strings.Contains(in, "/") {
+		s, err := netip.ParsePrefix(in)
+		if err != nil {
+			return nil, errors.New("invalid cidr notation.")
 		}
-		// Ignore the last IP as it's the broadcast IP which is unusable.
-		// If /31 then assume a point-to-point link and return upper address.
-		if prefixLen < 31 {
-			network.IP[3]--
+		ok = s.Bits() >= p.Bits() && p.Contains(s.Addr())
+	} else {
+		ip, err := netip.ParseAddr(in)
+		if err != nil {
+			return nil, errors.New("invalid ip.")
 		}
-		last := network.IP.To4().String()
+		ok = p.Contains(ip)
+	}
 
-		// Get the size of subnet.
-		size := 1 << (uint64(bits) - uint64(prefixLen))
+	r := fmt.Sprintf("%s %d TXT \"%s is not in %s\"", q, TTL, in, p)
+	if ok {
+		r = fmt.Sprintf("%s %d TXT \"%s is in %s\"", q, TTL, in, p)
+	}
+
+	return []string{r}, nil
+}
+
+// rangeToCIDR returns the prefixes that cover an IP range,
+// eg: 10.0.0.0-10.0.3.255.
+func (c *CIDR) rangeToCIDR(q, start, end string) ([]string, error) {
+	a, err := netip.ParseAddr(start)
+	if err != nil {
+		return nil, errors.New("invalid ip.")
+	}
+	b, err := netip.ParseAddr(end)
+	if err != nil {
+		return nil, errors.New("invalid ip.")
+	}
+	if a.Is4() != b.Is4() || a.Compare(b) > 0 {
+		return nil, errors.New("invalid ip range.")
+	}
+
+	prefixes, ok := rangePrefixes(a, b, maxSubnets)
+	if !ok {
+		return nil, fmt.Errorf("range too large. max is %d cidrs.", maxSubnets)
+	}
+
+	var (
+		out  = make([]string, 0, len(prefixes))
+		size = msgSize(q)
+	)
+	for _, p := range prefixes {
+		if size += recordSize(q, p.String()); size > maxSize {
+			return nil, fmt.Errorf("range too large. max is %d cidrs.", maxSubnets)
+		}
+		out = append(out, fmt.Sprintf("%s %d TXT \"%s\"", q, TTL, p))
+	}
+
+	return out, nil
+}
+
+// aggregate returns the smallest list of prefixes that cover a list of
+// prefixes and the prefixes in the list that overlap,
+// eg: 10.0.0.0/24,10.0.1.0/24,10.0.0.128/25.
+func (c *CIDR) aggregate(q string) ([]string, error) {
+	list := strings.Split(q, ",")
+	if len(list) > maxPrefixes {
+		return nil, fmt.Errorf("too many prefixes. max is %d.", maxPrefixes)
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr notation: %s.", s)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+
+	// Merge the address ranges of the prefixes that overlap or are adjacent.
+	type span struct{ start, end netip.Addr }
+	spans := make([]span, 0, len(prefixes))
+	for _, p := range prefixes {
+		spans = append(spans, span{p.Addr(), lastAddr(p)})
+	}
+	slices.SortFunc(spans, func(a, b span) int {
+		return a.start.Compare(b.start)
+	})
+
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		m := &merged[len(merged)-1]
+		if s.start.Is4() != m.start.Is4() {
+			merged = append(merged, s)
+			continue
+		}
+
+		next := m.end.Next()
+		if s.start.Compare(m.end) <= 0 || s.start == next {
+			if s.end.Compare(m.end) > 0 {
+				m.end = s.end
+			}
+			continue
+		}
+
+		merged = append(merged, s)
+	}
+
+	var (
+		out  []string
+		size = msgSize(q)
+	)
+	add := func(txt string) bool {
+		if size += recordSize(q, txt); size > maxSize || len(out) >= maxSubnets {
+			return false
+		}
+		out = append(out, fmt.Sprintf("%s %d TXT \"%s\"", q, TTL, txt))
+		return true
+	}
 
-		r := fmt.Sprintf("%s %d TXT \"%s\" \"%s\" \"%d\"", q, TTL, first, last, size)
-		return []string{r}, nil
+	for _, s := range merged {
+		ps, ok := rangePrefixes(s.start, s.end, maxSubnets-len(out))
+		if !ok {
+			return nil, fmt.Errorf("result too large. max is %d records.", maxSubnets)
+		}
+		for _, p := range ps {
+			if !add(p.String()) {
+				return nil, fmt.Errorf("result too large. max is %d records.", maxSubnets)
+			}
+		}
+	}
 
-	// Handle ipv6.
-	case ipAddr.To16() != nil:
-		for i := range network.IP.To16() {
-			network.IP[i] &= network.Mask[i]
+	for i, a := range prefixes {
+		for _, b := range prefixes[i+1:] {
+			if !a.Overlaps(b) {
+				continue
+			}
+			if !add(fmt.Sprintf("%s overlaps %s", a, b)) {
+				return nil, fmt.Errorf("result too large. max is %d records.", maxSubnets)
+			}
 		}
-		first := network.IP.To16().String()
+	}
 
-		for i := range network.IP.To16() {
-			network.IP[i] |= ^network.Mask[i]
+	return out, nil
+}
+
+// rangePrefixes returns the smallest list of prefixes that cover the IPs
+// from a to b. ok is false if it takes more than max prefixes.
+func rangePrefixes(a, b netip.Addr, max int) (out []netip.Prefix, ok bool) {
+	for a.IsValid() && a.Compare(b) <= 0 {
+		if len(out) >= max {
+			return nil, false
+		}
+
+		// The largest prefix that starts at a and ends within b.
+		var p netip.Prefix
+		for n := 0; n <= a.BitLen(); n++ {
+			p = netip.PrefixFrom(a, n)
+			if p.Masked().Addr() == a && lastAddr(p).Compare(b) <= 0 {
+				break
+			}
 		}
-		last := network.IP.To16().String()
 
-		// uint32 won't suffice for IPv6 prefixes lesser than /65.
-		size := big.NewInt(1)
-		size = size.Lsh(size, uint(bits-prefixLen))
+		out = append(out, p)
+		a = lastAddr(p).Next()
+	}
 
-		r := fmt.Sprintf("%s %d TXT \"%s\" \"%s\" \"%d\"", q, TTL, first, last, size)
-		return []string{r}, nil
+	return out, true
+}
 
-	default:
-		return nil, errors.New("unable to parse ip.")
+// msgSize returns the size of a response message for a query without
+// any records, that is, the header and the question.
+func msgSize(q string) int {
+	// The question is $q.cidr. followed by its type and class.
+	return 12 + len(q) + len(".cidr.") + 1 + 4
+}
+
+// recordSize returns the uncompressed size of a TXT record for a query
+// with the given strings.
+func recordSize(q string, txt ...string) int {
+	// The name is $q. followed by the type, class, TTL, and data length.
+	n := len(q) + 2 + 10
+	for _, s := range txt {
+		n += 1 + len(s)
 	}
+
+	return n
 }
 
-// Dump produces a gob dump of the cached data.
-func (c *CIDR) Dump() ([]byte, error) {
-	return nil, nil
+// lastAddr returns the last IP in a prefix.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+
+	ip, _ := netip.AddrFromSlice(b)
+	return ip
+}
+
+// mask returns the netmask of a prefix as an IP, or the inverse wildcard
+// mask, eg: 255.255.255.0, 0.0.0.255.
+func mask(p netip.Prefix, wildcard bool) netip.Addr {
+	b := make([]byte, p.Addr().BitLen()/8)
+	for i := range b {
+		n := min(max(p.Bits()-i*8, 0), 8)
+		b[i] = ^byte(0xff >> n)
+		if wildcard {
+			b[i] = ^b[i]
+		}
+	}
+
+	ip, _ := netip.AddrFromSlice(b)
+	return ip
 }
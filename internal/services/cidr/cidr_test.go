@@ -0,0 +1,124 @@
+package cidr
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestQuery(t *testing.T) {
+	c := New()
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"10.0.0.0/24", []string{
+			`10.0.0.0/24 900 TXT "10.0.0.1" "10.0.0.254" "256"`,
+			`10.0.0.0/24 900 TXT "network 10.0.0.0" "netmask 255.255.255.0" "wildcard 0.0.0.255" "broadcast 10.0.0.255"`,
+		}},
+		{"10.0.0.5/20", []string{
+			`10.0.0.5/20 900 TXT "10.0.0.1" "10.0.15.254" "4096"`,
+			`10.0.0.5/20 900 TXT "network 10.0.0.0" "netmask 255.255.240.0" "wildcard 0.0.15.255" "broadcast 10.0.15.255"`,
+		}},
+		{"10.0.0.0/31", []string{
+			`10.0.0.0/31 900 TXT "10.0.0.0" "10.0.0.1" "2"`,
+			`10.0.0.0/31 900 TXT "network 10.0.0.0" "netmask 255.255.255.254" "wildcard 0.0.0.1" "broadcast 10.0.0.1"`,
+		}},
+		{"2001:db8::/32", []string{
+			`2001:db8::/32 900 TXT "2001:db8::" "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff" "79228162514264337593543950336"`,
+			`2001:db8::/32 900 TXT "network 2001:db8::" "netmask ffff:ffff::"`,
+		}},
+		{"10.0.0.0/22.split24", []string{
+			`10.0.0.0/22.split24 900 TXT "4 subnets"`,
+			`10.0.0.0/22.split24 900 TXT "10.0.0.0/24" "10.0.0.0 - 10.0.0.255"`,
+			`10.0.0.0/22.split24 900 TXT "10.0.1.0/24" "10.0.1.0 - 10.0.1.255"`,
+			`10.0.0.0/22.split24 900 TXT "10.0.2.0/24" "10.0.2.0 - 10.0.2.255"`,
+			`10.0.0.0/22.split24 900 TXT "10.0.3.0/24" "10.0.3.0 - 10.0.3.255"`,
+		}},
+		{"10.0.0.5-10.0.0.0/24", []string{`10.0.0.5-10.0.0.0/24 900 TXT "10.0.0.5 is in 10.0.0.0/24"`}},
+		{"10.0.1.5-10.0.0.0/24", []string{`10.0.1.5-10.0.0.0/24 900 TXT "10.0.1.5 is not in 10.0.0.0/24"`}},
+		{"10.0.1.0/24-10.0.0.0/16", []string{`10.0.1.0/24-10.0.0.0/16 900 TXT "10.0.1.0/24 is in 10.0.0.0/16"`}},
+		{"10.0.0.0/8-10.0.0.0/16", []string{`10.0.0.0/8-10.0.0.0/16 900 TXT "10.0.0.0/8 is not in 10.0.0.0/16"`}},
+		{"10.0.0.0-10.0.3.255", []string{`10.0.0.0-10.0.3.255 900 TXT "10.0.0.0/22"`}},
+		{"10.0.0.1-10.0.0.6", []string{
+			`10.0.0.1-10.0.0.6 900 TXT "10.0.0.1/32"`,
+			`10.0.0.1-10.0.0.6 900 TXT "10.0.0.2/31"`,
+			`10.0.0.1-10.0.0.6 900 TXT "10.0.0.4/31"`,
+			`10.0.0.1-10.0.0.6 900 TXT "10.0.0.6/32"`,
+		}},
+		{"0.0.0.0-255.255.255.255", []string{`0.0.0.0-255.255.255.255 900 TXT "0.0.0.0/0"`}},
+		{"10.0.0.0/24,10.0.1.0/24,10.0.0.128/25,192.168.0.0/24", []string{
+			`10.0.0.0/24,10.0.1.0/24,10.0.0.128/25,192.168.0.0/24 900 TXT "10.0.0.0/23"`,
+			`10.0.0.0/24,10.0.1.0/24,10.0.0.128/25,192.168.0.0/24 900 TXT "192.168.0.0/24"`,
+			`10.0.0.0/24,10.0.1.0/24,10.0.0.128/25,192.168.0.0/24 900 TXT "10.0.0.0/24 overlaps 10.0.0.128/25"`,
+		}},
+	}
+	for _, tc := range tests {
+		out, err := c.Query(tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.q, err)
+			continue
+		}
+		if !slices.Equal(out, tc.want) {
+			t.Errorf("%s: want %q got %q", tc.q, tc.want, out)
+		}
+		for _, r := range out {
+			if _, err := dns.NewRR(r); err != nil {
+				t.Errorf("%s: invalid RR: %v", r, err)
+			}
+		}
+	}
+
+	for _, q := range []string{"10.0.0.0", "10.0.0.0/33", "10.0.0.0/24.split16", "10.0.0.5-10.0.0.1", "10.0.0.1-::1", "10.0.0.0/24,x",
+		"::1-ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe", strings.Repeat("10.0.0.0/8,", 9) + "10.0.0.0/8"} {
+		if _, err := c.Query(q); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+}
+
+func TestResponseSize(t *testing.T) {
+	c := New()
+
+	out, err := c.Query("10.0.0.0/16.split24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) < 2 || len(out) > maxSubnets+1 || out[0] != `10.0.0.0/16.split24 900 TXT "256 subnets"` {
+		t.Errorf("unexpected subnets: %q", out)
+	}
+
+	// Responses should fit in a 512 byte UDP message without compression.
+	for _, q := range []string{"10.0.0.0/16.split24", "2001:db8::/32.split64", "2001:db8:abcd:1234::/64.split72",
+		"10.0.0.0-10.0.0.127", "10.0.0.0/24,10.0.2.0/24,10.0.0.0/25"} {
+		out, err := c.Query(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+
+		m := &dns.Msg{}
+		m.SetQuestion(dns.Fqdn(q+".cidr"), dns.TypeTXT)
+		for _, r := range out {
+			rr, err := dns.NewRR(r)
+			if err != nil {
+				t.Fatalf("%s: invalid RR: %v", r, err)
+			}
+			m.Answer = append(m.Answer, rr)
+		}
+		if n := m.Len(); n > maxSize {
+			t.Errorf("%s: response is %d bytes", q, n)
+		}
+	}
+
+	// Ranges and lists that don't fit are errors.
+	for _, q := range []string{"10.0.0.1-10.0.0.254", "2001:db8::1-2001:db8::ffff:ffff:ffff:fffe",
+		"10.0.0.0/24,10.0.2.0/24,10.0.4.0/24,10.0.6.0/24,10.0.0.0/25"} {
+		if _, err := c.Query(q); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/knadh/dns.toys/internal/geo"
 )
 
 // DIGIPIN_GRID maps coordinates to alphanumeric characters.
@@ -25,16 +27,19 @@ const (
 	TTL    = 900 // 15 minutes
 )
 
-type Digipin struct{}
+type Digipin struct {
+	// Optional. Names the city nearest to the location.
+	geo *geo.Geo
+}
 
 var (
 	reLatLong = regexp.MustCompile(`^(-?\d+\.?\d*),(-?\d+\.?\d*)$`)
 	reDigipin = regexp.MustCompile(`^([FC98J327K456LMPT-]+)$`)
 )
 
-// New returns a new instance of Digipin service.
-func New() *Digipin {
-	return &Digipin{}
+// New returns a new instance of Digipin service. g is optional.
+func New(g *geo.Geo) *Digipin {
+	return &Digipin{geo: g}
 }
 
 // Query handles digipin encoding/decoding queries.
@@ -49,7 +54,7 @@ func (d *Digipin) Query(q string) ([]string, error) {
 
 		}
 		result := fmt.Sprintf("%.6f,%.6f", lat, lng)
-		return []string{fmt.Sprintf(`%s %d TXT "%s"`, q, TTL, result)}, nil
+		return []string{fmt.Sprintf(`%s %d TXT "%s"%s`, q, TTL, result, d.near(lat, lng))}, nil
 	}
 
 	// Try to encode lat,lng to digipin
@@ -64,7 +69,7 @@ func (d *Digipin) Query(q string) ([]string, error) {
 			return nil, err
 		}
 
-		r := fmt.Sprintf(`%s %d TXT "%s"`, q, TTL, result)
+		r := fmt.Sprintf(`%s %d TXT "%s"%s`, q, TTL, result, d.near(lat, lng))
 		return []string{r}, nil
 	}
 
@@ -76,6 +81,20 @@ func (d *Digipin) Dump() ([]byte, error) {
 	return nil, nil
 }
 
+// near returns a TXT string naming the city nearest to the location, if any.
+func (d *Digipin) near(lat, lng float64) string {
+	if d.geo == nil {
+		return ""
+	}
+
+	n := d.geo.Nearest(lat, lng, 1)
+	if len(n) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(` "near %s"`, n[0])
+}
+
 // getDigipin encodes lat/lon into a 10-character DIGIPIN.
 func getDigipin(lat, lon float64) (string, error) {
 	if lat < minLat || lat > maxLat {
@@ -0,0 +1,111 @@
+package fx
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// currency represents an ISO 4217 currency.
+type currency struct {
+	Code       string   `json:"code"`
+	Name       string   `json:"name"`
+	Symbol     string   `json:"symbol"`
+	MinorUnits int      `json:"minor_units"`
+	Countries  []string `json:"countries"`
+}
+
+// majors is the list of currencies shown in cross rate listings.
+var majors = []string{"USD", "EUR", "JPY", "GBP", "CNY", "AUD", "CAD", "CHF", "INR", "HKD", "SGD"}
+
+// maxTargets is the max number of currencies to convert to in one query.
+const maxTargets = 10
+
+//go:embed currencies.json
+var currencyData []byte
+
+// currencies is the embedded ISO 4217 table by code.
+var currencies = loadCurrencies(currencyData)
+
+func loadCurrencies(b []byte) map[string]currency {
+	var list []currency
+	if err := json.Unmarshal(b, &list); err != nil {
+		panic(fmt.Sprintf("error loading currencies: %v", err))
+	}
+
+	out := make(map[string]currency, len(list))
+	for _, c := range list {
+		out[c.Code] = c
+	}
+
+	return out
+}
+
+// formatAmount formats an amount with the currency's decimal places. Amounts
+// that would round to 0 get more decimal places for 4 significant digits.
+// Codes that aren't ISO currencies, such as crypto currencies, are shown to
+// 6 significant digits as their units are often worth a lot.
+func formatAmount(v float64, code string) string {
+	c, ok := currencies[code]
+	if !ok {
+		return formatSig(v, 2, 6)
+	}
+
+	if v == 0 || math.Abs(v) >= 0.5*math.Pow10(-c.MinorUnits) {
+		return strconv.FormatFloat(v, 'f', c.MinorUnits, 64)
+	}
+
+	return formatSig(v, c.MinorUnits, 4)
+}
+
+// formatSig formats a value with at least minDec decimal places and as
+// many more as it takes to show sig significant digits, without trailing
+// zeros beyond minDec.
+func formatSig(v float64, minDec, sig int) string {
+	if v == 0 {
+		return strconv.FormatFloat(v, 'f', minDec, 64)
+	}
+
+	dec := max(minDec, sig-1-int(math.Floor(math.Log10(math.Abs(v)))))
+	s := strconv.FormatFloat(v, 'f', dec, 64)
+	if dec > minDec {
+		s = strings.TrimRight(s, "0")
+		if i := strings.IndexByte(s, '.'); len(s)-i-1 < minDec {
+			s += strings.Repeat("0", minDec-(len(s)-i-1))
+		}
+		s = strings.TrimSuffix(s, ".")
+	}
+
+	return s
+}
+
+// formatInfo returns the TXT strings for a currency's details.
+func formatInfo(c currency) string {
+	out := []string{
+		fmt.Sprintf(`"%s"`, c.Code),
+		fmt.Sprintf(`"%s"`, c.Name),
+		fmt.Sprintf(`"symbol = %s"`, c.Symbol),
+		fmt.Sprintf(`"minor units = %d"`, c.MinorUnits),
+	}
+
+	// TXT strings are limited to 255 bytes. Split long country lists.
+	var b strings.Builder
+	b.WriteString("countries = ")
+	for i, n := range c.Countries {
+		if i > 0 {
+			if b.Len()+len(n)+2 > 250 {
+				out = append(out, `"`+b.String()+`"`)
+				b.Reset()
+			} else {
+				b.WriteString(", ")
+			}
+		}
+		b.WriteString(n)
+	}
+	out = append(out, `"`+b.String()+`"`)
+
+	return strings.Join(out, " ")
+}
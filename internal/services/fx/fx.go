@@ -4,12 +4,10 @@ package fx
 import (
 	"bytes"
 	"encoding/gob"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -17,79 +15,214 @@ import (
 	"time"
 )
 
-const apiURL = "https://open.er-api.com/v6/latest/USD"
-
 // TTL is set to 900 seconds (15 minutes).
 const TTL = 900
 
-var reParse = regexp.MustCompile("([0-9\\.]*)([A-Z]{3})\\-([A-Z]{3})")
+// minTTL is the TTL for answers from stale rates.
+const minTTL = 60
+
+// histTTL is the TTL for historical answers that don't change.
+// Set to 1 day (60*60*24 = 86,400).
+const histTTL = 86400
+
+// maxWindow is the longest time-series window in days.
+const maxWindow = 3660
+
+var (
+	reParse  = regexp.MustCompile("([0-9\\.]*)([A-Z]{3,5})\\-([A-Z]{3,5}(?:,[A-Z]{3,5})*)(?:@([0-9]{4}\\-[0-9]{2}\\-[0-9]{2}))?")
+	reWindow = regexp.MustCompile("^([A-Z]{3,5})\\-([A-Z]{3,5})\\.([0-9]+)D$")
+	reInfo   = regexp.MustCompile("^([A-Z]{3,5})\\.INFO$")
+	reCross  = regexp.MustCompile("^([A-Z]{3,5})$")
+
+	errUnknown = errors.New("unknown currency")
+	errStale   = errors.New("stale rates")
+)
 
 // FX represents the currency coversion (Foreign Exchange) package.
 type FX struct {
 	opt  Opt
 	data data
 	mut  sync.RWMutex
+
+	// Daily rate history. nil if disabled.
+	hist *history
+
+	// Rate providers in the order of priority.
+	providers []provider
+
+	// Crypto and precious metal rates, refreshed separately from
+	// the fiat rates, and the time they were last fetched.
+	crypto          data
+	cryptoAt        time.Time
+	cryptoProviders []provider
+
+	// Time of the last fetch attempt that failed, for health reports.
+	lastErrAt time.Time
 }
 
 type data struct {
 	Base  string             `json:"base_code"`
 	Date  string             `json:"time_last_update_utc"`
 	Rates map[string]float64 `json:"rates"`
+
+	// Time the rates were fetched from the provider.
+	FetchedAt time.Time `json:"-"`
 }
 
 // Opt represents the config options for the FX converter.
 type Opt struct {
 	RefreshInterval time.Duration `json:"refresh_interval"`
+
+	// Optional path to the append-only daily rate history file.
+	HistoryFile string `json:"history_file"`
+
+	// Rate providers in the order of priority. The first one that responds
+	// is the primary and the rest are used to cross-check it.
+	// Defaults to open.er-api.com.
+	Providers []ProviderOpt `json:"providers"`
+
+	ReqTimeout time.Duration `json:"request_timeout"`
+
+	// Max fraction by which a rate may deviate from the median across
+	// providers before it's rejected, eg: 0.05. 0 disables the check.
+	MaxDeviation float64 `json:"max_deviation"`
+
+	// Optional crypto currency and precious metal providers. Their rates
+	// are merged into the conversion table.
+	CryptoProviders       []ProviderOpt `json:"crypto_providers"`
+	CryptoRefreshInterval time.Duration `json:"crypto_refresh_interval"`
+
+	// Crypto rates older than this are not served.
+	CryptoMaxAge time.Duration `json:"crypto_max_age"`
+
+	// Answers from rates older than StaleAfter are marked stale and rates
+	// older than MaxAge are not served. 0 disables either.
+	StaleAfter time.Duration `json:"stale_after"`
+	MaxAge     time.Duration `json:"max_age"`
 }
 
 // New returns an instace of the FX converter.
-func New(o Opt) *FX {
+func New(o Opt) (*FX, error) {
+	if len(o.Providers) == 0 {
+		o.Providers = []ProviderOpt{{Type: ProviderERAPI}}
+	}
+	if o.ReqTimeout == 0 {
+		o.ReqTimeout = 6 * time.Second
+	}
+	if o.CryptoRefreshInterval == 0 {
+		o.CryptoRefreshInterval = 10 * time.Minute
+	}
+	if o.CryptoMaxAge == 0 {
+		o.CryptoMaxAge = o.CryptoRefreshInterval * 3
+	}
+
 	fx := &FX{
 		opt: o,
 	}
 
+	for _, po := range o.Providers {
+		p, err := newProvider(po, o.ReqTimeout)
+		if err != nil {
+			return nil, err
+		}
+		fx.providers = append(fx.providers, p)
+	}
+
+	for _, po := range o.CryptoProviders {
+		p, err := newProvider(po, o.ReqTimeout)
+		if err != nil {
+			return nil, err
+		}
+		fx.cryptoProviders = append(fx.cryptoProviders, p)
+	}
+
+	if o.HistoryFile != "" {
+		h, err := loadHistory(o.HistoryFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading fx history: %v", err)
+		}
+		fx.hist = h
+		log.Printf("%d days of fx history loaded", len(h.days))
+	}
+
 	// Periodically fetch and refresh the rates.
 	go func() {
 		for {
-			log.Println("loading fx API")
-			d, err := fx.load(apiURL)
+			log.Println("loading fx rates")
+			d, err := fx.fetch()
 			if err != nil {
-				log.Printf("error loading fx rates API: %v", err)
+				log.Printf("error loading fx rates: %v", err)
 
-				// HTTP fetch failed. Retry again in a minute.
-				time.Sleep(time.Minute)
-				continue
-			}
+				fx.mut.Lock()
+				fx.lastErrAt = time.Now()
+				fx.mut.Unlock()
 
-			if _, ok := d.Rates[d.Base]; !ok {
-				log.Printf("base currency %s not found in rates", d.Base)
-				time.Sleep(time.Minute * 5)
+				// Fetch failed. Retry again in a minute.
+				time.Sleep(time.Minute)
 				continue
 			}
 			log.Printf("%d fx currency pairs loaded", len(d.Rates))
+			d.FetchedAt = time.Now()
 
 			fx.mut.Lock()
 			fx.data = d
 			fx.mut.Unlock()
 
+			fx.record(d)
+
 			time.Sleep(o.RefreshInterval)
 		}
 	}()
 
-	return fx
+	if len(fx.cryptoProviders) > 0 {
+		go fx.runCrypto()
+	}
+
+	return fx, nil
 }
 
 // Query handles a currency rate conversion query.
-// Format: 100USD-INR.FX
+// Format: 100USD-INR.FX, 100USD-INR,EUR,GBP.FX, 100USD-INR@2024-03-01.FX,
+// USD-INR.30D.FX, USD.FX, INR.INFO.FX
 func (fx *FX) Query(q string) ([]string, error) {
-	if len(fx.data.Rates) == 0 {
-		return nil, errors.New("fx data unavailable. Please try later.")
+	q = strings.ToUpper(q)
+
+	// Time-series window.
+	if res := reWindow.FindStringSubmatch(q); len(res) == 4 {
+		return fx.queryWindow(q, res[1], res[2], res[3])
 	}
 
-	q = strings.ToUpper(q)
+	// Currency details.
+	if res := reInfo.FindStringSubmatch(q); len(res) == 2 {
+		c, ok := currencies[res[1]]
+		if !ok {
+			return nil, fmt.Errorf("unknown currency '%s'.", res[1])
+		}
+
+		return []string{fmt.Sprintf("%s %d TXT %s", q, histTTL, formatInfo(c))}, nil
+	}
+
+	// Cross rates against the major currencies.
+	if res := reCross.FindStringSubmatch(q); len(res) == 2 {
+		// Skip the majors that the providers don't have rates for.
+		to := make([]string, 0, len(majors))
+		fx.mut.RLock()
+		for _, c := range majors {
+			if _, ok := fx.data.Rates[c]; ok && c != res[1] {
+				to = append(to, c)
+			}
+		}
+		fx.mut.RUnlock()
+
+		if len(to) == 0 {
+			return nil, errors.New("cross rates are unavailable. Please try later.")
+		}
+
+		return fx.convert(q, 1, res[1], to)
+	}
 
 	res := reParse.FindStringSubmatch(q)
-	if len(res) != 4 {
+	if len(res) != 5 {
 		return nil, errors.New("invalid fx query.")
 	}
 
@@ -107,33 +240,306 @@ func (fx *FX) Query(q string) ([]string, error) {
 
 	var (
 		from = res[2]
-		to   = res[3]
+		to   = strings.Split(res[3], ",")
 	)
+	if len(to) > maxTargets {
+		return nil, fmt.Errorf("too many currencies. Max is %d.", maxTargets)
+	}
+
+	// Historical conversion.
+	if res[4] != "" {
+		return fx.queryHistory(q, val, from, to, res[4])
+	}
+
+	return fx.convert(q, val, from, to)
+}
+
+// convert converts an amount from a currency to one or more currencies
+// using the latest rates.
+func (fx *FX) convert(q string, val float64, from string, to []string) ([]string, error) {
+	fx.mut.RLock()
+	defer fx.mut.RUnlock()
+
+	if len(fx.data.Rates) == 0 {
+		return nil, errors.New("fx data unavailable. Please try later.")
+	}
+
+	age := fx.age()
+	if fx.opt.MaxAge > 0 && age > fx.opt.MaxAge {
+		return nil, errors.New("fx rates are too old. Please try later.")
+	}
 
 	// Validate the currency names.
+	fromRate, isCrypto, err := fx.rate(from)
+	if err != nil {
+		return nil, rateErr(err, "from", from)
+	}
+
+	out := make([]string, 0, len(to)+1)
+	for _, t := range to {
+		toRate, toCrypto, err := fx.rate(t)
+		if err != nil {
+			return nil, rateErr(err, "to", t)
+		}
+		isCrypto = isCrypto || toCrypto
+
+		// Convert.
+		conv := toRate / fromRate * val
+		out = append(out, fmt.Sprintf(`"%s %s = %s %s"`, formatAmount(val, from), from, formatAmount(conv, t), t))
+	}
+
+	date := fx.data.Date
+	if isCrypto {
+		date = fx.crypto.Date
+	}
+	out = append(out, `"`+date+`"`)
+
+	if fx.isStale(age) {
+		out = append(out, fmt.Sprintf(`"stale: rates are %s old"`, age.Truncate(time.Minute)))
+	}
+
+	r := fmt.Sprintf("%s %d TXT %s", q, fx.ttl(age), strings.Join(out, " "))
+
+	return []string{r}, nil
+}
+
+// age returns the age of the fiat rates. For snapshots from older versions
+// without the fetch time, the provider's update time is used.
+// fx.mut should be read-locked by the caller.
+func (fx *FX) age() time.Duration {
+	t := fx.data.FetchedAt
+	if t.IsZero() {
+		d, err := time.Parse(time.RFC1123Z, fx.data.Date)
+		if err != nil {
+			// Unknown age. Assume it's as old as it can be.
+			return time.Duration(math.MaxInt64)
+		}
+		t = d
+	}
+
+	return time.Since(t)
+}
+
+func (fx *FX) isStale(age time.Duration) bool {
+	return fx.opt.StaleAfter > 0 && age > fx.opt.StaleAfter
+}
+
+// ttl returns the TTL for answers from rates of the given age. It's
+// lowered proportionately as the rates approach staleness so that
+// resolvers don't cache aging answers for long.
+func (fx *FX) ttl(age time.Duration) int {
+	if fx.opt.StaleAfter <= 0 {
+		return TTL
+	}
+	if fx.isStale(age) {
+		return minTTL
+	}
+
+	t := int(math.Round(float64(TTL) * (1 - float64(age)/float64(fx.opt.StaleAfter))))
+	if t < minTTL {
+		return minTTL
+	}
+	return t
+}
+
+// Health returns the fx service's health: when the rates were last fetched,
+// their age and whether they're stale.
+func (fx *FX) Health() []string {
 	fx.mut.RLock()
-	fromRate, ok := fx.data.Rates[from]
-	fx.mut.RUnlock()
+	defer fx.mut.RUnlock()
+
+	if len(fx.data.Rates) == 0 {
+		return []string{"status = unavailable"}
+	}
+
+	age := fx.age()
+	status := "ok"
+	switch {
+	case fx.opt.MaxAge > 0 && age > fx.opt.MaxAge:
+		status = "expired"
+	case fx.isStale(age):
+		status = "stale"
+	}
+
+	out := []string{"status = " + status}
+	if !fx.data.FetchedAt.IsZero() {
+		out = append(out, "last success = "+fx.data.FetchedAt.UTC().Format(time.RFC3339))
+	}
+	out = append(out, "rates date = "+fx.data.Date)
+	if fx.lastErrAt.After(fx.data.FetchedAt) {
+		out = append(out, "last error = "+fx.lastErrAt.UTC().Format(time.RFC3339))
+	}
+	if len(fx.cryptoProviders) > 0 {
+		last := "never"
+		if !fx.cryptoAt.IsZero() {
+			last = fx.cryptoAt.UTC().Format(time.RFC3339)
+		}
+		out = append(out, "crypto last success = "+last)
+	}
+
+	return out
+}
+
+// rate returns the rate of a currency relative to the fiat base currency.
+// Codes that aren't in the fiat rates are looked up in the crypto and metal
+// rates, which are rebased to the fiat base. The bool indicates whether the
+// rate is a crypto/metal rate. fx.mut should be read-locked by the caller.
+func (fx *FX) rate(code string) (float64, bool, error) {
+	if r, ok := fx.data.Rates[code]; ok {
+		return r, false, nil
+	}
 
+	c, ok := fx.crypto.Rates[code]
 	if !ok {
-		return nil, fmt.Errorf("unknown from currency '%s'.", from)
+		return 0, false, errUnknown
 	}
 
-	toRate, ok := fx.data.Rates[to]
+	// Crypto rates are relative to their own base (USD), which should
+	// be available in the fiat rates.
+	base, ok := fx.data.Rates[fx.crypto.Base]
 	if !ok {
-		return nil, fmt.Errorf("unknown to currency '%s'.", to)
+		return 0, false, errUnknown
+	}
+
+	if time.Since(fx.cryptoAt) > fx.opt.CryptoMaxAge {
+		return 0, true, errStale
+	}
+
+	return c * base, true, nil
+}
+
+func rateErr(err error, dir, code string) error {
+	if err == errStale {
+		return fmt.Errorf("rates for '%s' are stale. Please try later.", code)
+	}
+
+	return fmt.Errorf("unknown %s currency '%s'.", dir, code)
+}
+
+// queryHistory converts currencies using the recorded rates of a past date.
+func (fx *FX) queryHistory(q string, val float64, from string, to []string, date string) ([]string, error) {
+	if fx.hist == nil {
+		return nil, errors.New("fx history is not enabled.")
+	}
+
+	t, err := time.Parse(dateFormat, date)
+	if err != nil {
+		return nil, errors.New("invalid date. Use YYYY-MM-DD.")
+	}
+
+	d, ok := fx.hist.at(t)
+	if !ok {
+		return nil, fmt.Errorf("no fx history for %s.", date)
+	}
+
+	out := make([]string, 0, len(to)+1)
+	for _, t := range to {
+		rate, err := convRate(d.Rates, from, t)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, fmt.Sprintf(`"%s %s = %s %s"`, formatAmount(val, from), from, formatAmount(rate*val, t), t))
+	}
+
+	// Answers from the closest earlier day change once the date is
+	// recorded, so they aren't cached for long.
+	ttl := histTTL
+	if !d.Date.Equal(t) {
+		ttl = TTL
+	}
+
+	r := fmt.Sprintf("%s %d TXT %s \"%s\"", q, ttl, strings.Join(out, " "), d.Date.Format(dateFormat))
+	return []string{r}, nil
+}
+
+// queryWindow returns the min, max, average and change of a currency pair
+// over the last N days of history.
+func (fx *FX) queryWindow(q, from, to, days string) ([]string, error) {
+	if fx.hist == nil {
+		return nil, errors.New("fx history is not enabled.")
+	}
+
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 1 || n > maxWindow {
+		return nil, fmt.Errorf("invalid window. Max is %dd.", maxWindow)
 	}
 
-	baseRate := fx.data.Rates[fx.data.Base]
+	list := fx.hist.since(time.Now().UTC().AddDate(0, 0, -n))
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no fx history for the last %d days.", n)
+	}
+
+	var (
+		minRate, maxRate float64
+		minDay, maxDay   time.Time
+		sum, first       float64
+		last             float64
+		count            int
+	)
+	for _, d := range list {
+		rate, err := convRate(d.Rates, from, to)
+		if err != nil {
+			continue
+		}
+
+		if count == 0 {
+			first = rate
+		}
+		if count == 0 || rate < minRate {
+			minRate, minDay = rate, d.Date
+		}
+		if count == 0 || rate > maxRate {
+			maxRate, maxDay = rate, d.Date
+		}
+		last = rate
+		sum += rate
+		count++
+	}
 
-	// Convert.
-	conv := (baseRate / fromRate) / (baseRate / toRate) * val
+	if count == 0 {
+		return nil, fmt.Errorf("no fx history for %s-%s.", from, to)
+	}
 
-	r := fmt.Sprintf("%s %d TXT \"%0.2f %s = %0.2f %s\" \"%s\"", q, TTL, val, from, conv, to, fx.data.Date)
+	r := fmt.Sprintf("%s %d TXT \"%s-%s %dd\" \"min = %0.4f (%s)\" \"max = %0.4f (%s)\" \"avg = %0.4f\" \"change = %+0.2f%%\" \"%s to %s\"",
+		q, TTL, from, to, n,
+		minRate, minDay.Format(dateFormat), maxRate, maxDay.Format(dateFormat),
+		sum/float64(count), (last-first)/first*100,
+		list[0].Date.Format(dateFormat), list[len(list)-1].Date.Format(dateFormat))
 
 	return []string{r}, nil
 }
 
+// record adds the day's rates to the history if it's enabled.
+func (fx *FX) record(d data) {
+	if fx.hist == nil {
+		return
+	}
+
+	date, err := time.Parse(time.RFC1123Z, d.Date)
+	if err != nil {
+		date = time.Now()
+	}
+
+	if err := fx.hist.add(date, d.Rates); err != nil {
+		log.Printf("error recording fx history: %v", err)
+	}
+}
+
+// convRate returns the from->to conversion rate from a rates map.
+func convRate(rates map[string]float64, from, to string) (float64, error) {
+	fromRate, ok := rates[from]
+	if !ok || fromRate == 0 {
+		return 0, fmt.Errorf("unknown from currency '%s'.", from)
+	}
+
+	toRate, ok := rates[to]
+	if !ok {
+		return 0, fmt.Errorf("unknown to currency '%s'.", to)
+	}
+
+	return toRate / fromRate, nil
+}
+
 // Dump produces a gob dump of the cached data.
 func (fx *FX) Dump() ([]byte, error) {
 	buf := &bytes.Buffer{}
@@ -158,30 +564,52 @@ func (fx *FX) Load(b []byte) error {
 	return err
 }
 
-func (fx *FX) load(url string) (data, error) {
-	client := http.Client{
-		Timeout: 6 * time.Second,
-	}
+// runCrypto periodically fetches and refreshes crypto and metal rates.
+func (fx *FX) runCrypto() {
+	for {
+		d, err := fetchAll(fx.cryptoProviders, 0)
+		if err != nil {
+			log.Printf("error loading crypto rates: %v", err)
+			time.Sleep(time.Minute)
+			continue
+		}
+		log.Printf("%d crypto/metal rates loaded", len(d.Rates))
 
-	req, _ := http.NewRequest("GET", url, nil)
-	resp, err := client.Do(req)
-	if err != nil {
-		return data{}, err
-	}
+		fx.mut.Lock()
+		fx.crypto = d
+		fx.cryptoAt = time.Now()
+		fx.mut.Unlock()
 
-	if resp.StatusCode != http.StatusOK {
-		return data{}, fmt.Errorf("request failed: %v", resp.StatusCode)
+		time.Sleep(fx.opt.CryptoRefreshInterval)
 	}
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return data{}, err
+// fetch fetches rates from all the providers and cross-checks them.
+func (fx *FX) fetch() (data, error) {
+	return fetchAll(fx.providers, fx.opt.MaxDeviation)
+}
+
+// fetchAll fetches rates from a list of providers and merges them.
+func fetchAll(provs []provider, maxDev float64) (data, error) {
+	var list []data
+	for _, p := range provs {
+		d, err := p.fetch()
+		if err != nil {
+			log.Printf("error fetching fx rates from %s: %v", p.name(), err)
+			continue
+		}
+
+		if _, ok := d.Rates[d.Base]; !ok {
+			log.Printf("base currency %s not found in %s rates", d.Base, p.name())
+			continue
+		}
+
+		list = append(list, d)
 	}
 
-	var out data
-	if err := json.Unmarshal(body, &out); err != nil {
-		return data{}, err
+	if len(list) == 0 {
+		return data{}, errors.New("no fx provider returned rates")
 	}
 
-	return out, nil
+	return crossCheck(list, maxDev), nil
 }
@@ -0,0 +1,143 @@
+package fx
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	fx := &FX{
+		data: data{
+			Base: "USD",
+			Date: "Fri, 01 Mar 2024 00:02:31 +0000",
+			Rates: map[string]float64{
+				"USD": 1, "EUR": 0.925, "INR": 82.9, "GBP": 0.79, "JPY": 150.123,
+				"KWD": 0.30745, "CNY": 7.2, "AUD": 1.53, "CAD": 1.35, "CHF": 0.88,
+				"HKD": 7.82, "SGD": 1.34,
+			},
+		},
+	}
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"100USD-INR", []string{`"100.00 USD = 8290.00 INR"`}},
+		{"100USD-INR,EUR,GBP", []string{`"100.00 USD = 8290.00 INR" "100.00 USD = 92.50 EUR" "100.00 USD = 79.00 GBP"`}},
+		{"10USD-JPY,KWD", []string{`"10.00 USD = 1501 JPY" "10.00 USD = 3.075 KWD"`}},
+		{"1USD-EUR", []string{`"1.00 USD = 0.93 EUR"`}},
+		{"USD", []string{`"1.00 USD = 0.93 EUR"`, `"1.00 USD = 150 JPY"`, `"1.00 USD = 82.90 INR"`}},
+		{"INR.INFO", []string{`"Indian Rupee"`, `"minor units = 2"`, `"countries = India, Bhutan"`}},
+	}
+	for _, tc := range tests {
+		out, err := fx.Query(tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.q, err)
+			continue
+		}
+		for _, w := range tc.want {
+			if !strings.Contains(out[0], w) {
+				t.Errorf("%s: want %s in %s", tc.q, w, out[0])
+			}
+		}
+	}
+
+	// The listing shouldn't include the base currency.
+	out, _ := fx.Query("USD")
+	if strings.Contains(out[0], "= 1.00 USD") {
+		t.Errorf("cross rates shouldn't include the base: %s", out[0])
+	}
+
+	// Majors without rates are skipped.
+	delete(fx.data.Rates, "GBP")
+	out, err := fx.Query("USD")
+	if err != nil || strings.Contains(out[0], "GBP") || !strings.Contains(out[0], "= 0.93 EUR") {
+		t.Errorf("unexpected cross rates without GBP: %v %v", out, err)
+	}
+
+	if _, err := (&FX{data: data{Rates: map[string]float64{"USD": 1}}}).Query("USD"); err == nil {
+		t.Errorf("expected error without cross rates")
+	}
+
+	// Long country lists are split into multiple TXT strings.
+	out, _ = fx.Query("EUR.INFO")
+	for _, s := range strings.Split(out[0], `" "`) {
+		if len(s) > 255 {
+			t.Errorf("TXT string too long: %d", len(s))
+		}
+	}
+
+	for _, q := range []string{"1USD-XYZ", "XYZ.INFO", "XYZ", "1USD-A,B", "1USD-EUR,EUR,EUR,EUR,EUR,EUR,EUR,EUR,EUR,EUR,EUR"} {
+		if _, err := fx.Query(q); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+}
+
+func TestStaleness(t *testing.T) {
+	fx := &FX{
+		opt: Opt{StaleAfter: time.Hour * 10, MaxAge: time.Hour * 20},
+		data: data{
+			Base:      "USD",
+			Date:      "Fri, 01 Mar 2024 00:02:31 +0000",
+			Rates:     map[string]float64{"USD": 1, "INR": 82.9},
+			FetchedAt: time.Now().Add(-time.Hour * 5),
+		},
+	}
+
+	// Fresh, with the TTL lowered proportionately.
+	out, err := fx.Query("1USD-INR")
+	if err != nil || strings.Contains(out[0], "stale") || !strings.Contains(out[0], " 450 TXT ") {
+		t.Errorf("unexpected fresh answer: %v %v", out, err)
+	}
+
+	// Stale.
+	fx.data.FetchedAt = time.Now().Add(-time.Hour * 12)
+	out, err = fx.Query("1USD-INR")
+	if err != nil || !strings.Contains(out[0], `"stale: rates are 12h0m0s old"`) || !strings.Contains(out[0], " 60 TXT ") {
+		t.Errorf("unexpected stale answer: %v %v", out, err)
+	}
+	if h := fx.Health(); h[0] != "status = stale" {
+		t.Errorf("unexpected health: %v", h)
+	}
+
+	// Too old.
+	fx.data.FetchedAt = time.Now().Add(-time.Hour * 21)
+	if _, err := fx.Query("1USD-INR"); err == nil {
+		t.Errorf("expected error for expired rates")
+	}
+	if h := fx.Health(); h[0] != "status = expired" {
+		t.Errorf("unexpected health: %v", h)
+	}
+
+	// Snapshots without the fetch time fall back to the rates' date.
+	fx.data.FetchedAt = time.Time{}
+	if _, err := fx.Query("1USD-INR"); err == nil {
+		t.Errorf("expected error for old snapshot rates")
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	for _, tc := range []struct {
+		v    float64
+		code string
+		want string
+	}{
+		{0.925, "EUR", "0.93"},
+		{1234.5678, "KWD", "1234.568"},
+		{150.4, "JPY", "150"},
+		{0.4, "JPY", "0.4"},
+		{0.001234, "EUR", "0.001234"},
+		{0.00001234567, "BTC", "0.0000123457"},
+		{0.015625, "BTC", "0.015625"},
+		{0.5, "BTC", "0.50"},
+		{10, "USDT", "10.00"},
+		{123456.789, "XAU", "123456.79"},
+		{0, "USD", "0.00"},
+	} {
+		if got := formatAmount(tc.v, tc.code); got != tc.want {
+			t.Errorf("%v %s: want %s got %s", tc.v, tc.code, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package fx
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+// history is a rolling, on-disk record of daily rates. The file is
+// append-only with one line per day:
+// 2024-03-01 AED=3.6725,AFN=70.9,...
+// Rates are relative to the same base currency as the live data.
+type history struct {
+	path string
+
+	// Daily rates sorted by date.
+	days []day
+	mut  sync.RWMutex
+}
+
+type day struct {
+	Date  time.Time
+	Rates map[string]float64
+}
+
+// loadHistory loads the history file at the given path. A missing
+// file is not an error and results in an empty history.
+func loadHistory(path string) (*history, error) {
+	h := &history{path: path}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return h, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		d, err := parseDay(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		h.days = append(h.days, d)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(h.days, func(i, j int) bool {
+		return h.days[i].Date.Before(h.days[j].Date)
+	})
+
+	return h, nil
+}
+
+// add records the rates for a day unless the day is already recorded.
+func (h *history) add(date time.Time, rates map[string]float64) error {
+	date = date.UTC().Truncate(time.Hour * 24)
+
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	if len(h.days) > 0 && !h.days[len(h.days)-1].Date.Before(date) {
+		return nil
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d := day{Date: date, Rates: rates}
+	if _, err := f.WriteString(formatDay(d) + "\n"); err != nil {
+		return err
+	}
+
+	h.days = append(h.days, d)
+	return nil
+}
+
+// at returns the rates for the given date, or the closest earlier day
+// if there are no rates for the date. Dates after the last recorded day
+// aren't known yet.
+func (h *history) at(date time.Time) (day, bool) {
+	h.mut.RLock()
+	defer h.mut.RUnlock()
+
+	if len(h.days) == 0 || date.After(h.days[len(h.days)-1].Date) {
+		return day{}, false
+	}
+
+	i := sort.Search(len(h.days), func(i int) bool {
+		return h.days[i].Date.After(date)
+	})
+	if i == 0 {
+		return day{}, false
+	}
+
+	return h.days[i-1], true
+}
+
+// since returns the days recorded on or after the given date.
+func (h *history) since(date time.Time) []day {
+	h.mut.RLock()
+	defer h.mut.RUnlock()
+
+	i := sort.Search(len(h.days), func(i int) bool {
+		return !h.days[i].Date.Before(date)
+	})
+
+	return h.days[i:]
+}
+
+func formatDay(d day) string {
+	codes := make([]string, 0, len(d.Rates))
+	for c := range d.Rates {
+		codes = append(codes, c)
+	}
+	sort.Strings(codes)
+
+	var b strings.Builder
+	b.WriteString(d.Date.Format(dateFormat))
+	b.WriteByte(' ')
+	for i, c := range codes {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(c)
+		b.WriteByte('=')
+		b.WriteString(strconv.FormatFloat(d.Rates[c], 'g', 8, 64))
+	}
+
+	return b.String()
+}
+
+func parseDay(l string) (day, error) {
+	date, rates, ok := strings.Cut(strings.TrimSpace(l), " ")
+	if !ok {
+		return day{}, errors.New("invalid history line")
+	}
+
+	t, err := time.Parse(dateFormat, date)
+	if err != nil {
+		return day{}, fmt.Errorf("invalid date: %v", err)
+	}
+
+	d := day{Date: t, Rates: make(map[string]float64)}
+	for _, p := range strings.Split(rates, ",") {
+		code, val, ok := strings.Cut(p, "=")
+		if !ok {
+			return day{}, fmt.Errorf("invalid rate: %s", p)
+		}
+
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return day{}, fmt.Errorf("invalid rate: %s", p)
+		}
+		d.Rates[code] = v
+	}
+
+	return d, nil
+}
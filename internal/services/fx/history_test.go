@@ -0,0 +1,91 @@
+package fx
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fx.history")
+
+	h, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("error loading empty history: %v", err)
+	}
+
+	var (
+		now  = time.Now().UTC()
+		days = []struct {
+			date time.Time
+			inr  float64
+		}{
+			{now.AddDate(0, 0, -4), 80},
+			{now.AddDate(0, 0, -2), 84},
+			{now.AddDate(0, 0, -1), 82},
+			{now, 88},
+		}
+	)
+	for _, d := range days {
+		if err := h.add(d.date, map[string]float64{"USD": 1, "INR": d.inr, "EUR": 0.5}); err != nil {
+			t.Fatalf("error adding history: %v", err)
+		}
+	}
+
+	// Duplicate days should be ignored.
+	if err := h.add(now, map[string]float64{"USD": 1, "INR": 1}); err != nil {
+		t.Fatalf("error adding history: %v", err)
+	}
+
+	// Reload from disk.
+	h, err = loadHistory(path)
+	if err != nil {
+		t.Fatalf("error reloading history: %v", err)
+	}
+	if len(h.days) != len(days) {
+		t.Fatalf("want %d days got %d", len(days), len(h.days))
+	}
+
+	fx := &FX{hist: h}
+
+	// Historical conversion from the closest earlier day.
+	date := now.AddDate(0, 0, -2).Format(dateFormat)
+	out, err := fx.Query("10EUR-INR@" + date)
+	if err != nil {
+		t.Fatalf("error querying history: %v", err)
+	}
+	if !strings.Contains(out[0], "10.00 EUR = 1680.00 INR") || !strings.Contains(out[0], date) ||
+		!strings.Contains(out[0], " 86400 TXT ") {
+		t.Errorf("unexpected history answer: %s", out[0])
+	}
+
+	// Days without rates fall back to the closest earlier day that isn't
+	// cached for long.
+	date = now.AddDate(0, 0, -4).Format(dateFormat)
+	out, err = fx.Query("1USD-INR@" + now.AddDate(0, 0, -3).Format(dateFormat))
+	if err != nil {
+		t.Fatalf("error querying history: %v", err)
+	}
+	if !strings.Contains(out[0], "1.00 USD = 80.00 INR") || !strings.Contains(out[0], date) ||
+		!strings.Contains(out[0], " 900 TXT ") {
+		t.Errorf("unexpected history answer: %s", out[0])
+	}
+
+	for _, d := range []string{"1990-01-01", now.AddDate(0, 0, 1).Format(dateFormat), "2999-01-01"} {
+		if _, err := fx.Query("1USD-INR@" + d); err == nil {
+			t.Errorf("%s: expected error for date outside history", d)
+		}
+	}
+
+	// Window.
+	out, err = fx.Query("USD-INR.30d")
+	if err != nil {
+		t.Fatalf("error querying window: %v", err)
+	}
+	for _, s := range []string{"min = 80.0000", "max = 88.0000", "avg = 83.5000", "change = +10.00%"} {
+		if !strings.Contains(out[0], s) {
+			t.Errorf("window answer missing %q: %s", s, out[0])
+		}
+	}
+}
@@ -0,0 +1,449 @@
+package fx
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported rate provider types.
+const (
+	ProviderERAPI     = "erapi"
+	ProviderECB       = "ecb"
+	ProviderFile      = "file"
+	ProviderCoinGecko = "coingecko"
+	ProviderGoldAPI   = "goldapi"
+)
+
+// Default provider URLs.
+const (
+	erAPIURL     = "https://open.er-api.com/v6/latest/USD"
+	ecbURL       = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	coinGeckoURL = "https://api.coingecko.com/api/v3/simple/price"
+	goldAPIURL   = "https://api.gold-api.com/price"
+)
+
+// coinGeckoIDs maps crypto currency symbols to CoinGecko coin IDs.
+var coinGeckoIDs = map[string]string{
+	"BTC":  "bitcoin",
+	"ETH":  "ethereum",
+	"USDT": "tether",
+	"USDC": "usd-coin",
+	"BNB":  "binancecoin",
+	"SOL":  "solana",
+	"XRP":  "ripple",
+	"ADA":  "cardano",
+	"DOGE": "dogecoin",
+	"TRX":  "tron",
+	"DOT":  "polkadot",
+	"LTC":  "litecoin",
+	"XMR":  "monero",
+}
+
+// metals is the list of precious metal symbols (troy ounce prices).
+var metals = []string{"XAU", "XAG", "XPT", "XPD"}
+
+// ProviderOpt represents the config for a rate provider.
+type ProviderOpt struct {
+	// Provider type: erapi, ecb, file.
+	Type string `koanf:"type"`
+
+	// URL of the API for erapi and ecb and the path to a JSON or CSV
+	// file for file. Optional for erapi and ecb.
+	URL string `koanf:"url"`
+}
+
+// provider fetches the latest rates from a source.
+type provider interface {
+	name() string
+	fetch() (data, error)
+}
+
+// newProvider returns a provider for the given config.
+func newProvider(o ProviderOpt, timeout time.Duration) (provider, error) {
+	client := &http.Client{Timeout: timeout}
+
+	switch o.Type {
+	case ProviderERAPI:
+		if o.URL == "" {
+			o.URL = erAPIURL
+		}
+		return &erAPI{url: o.URL, client: client}, nil
+	case ProviderECB:
+		if o.URL == "" {
+			o.URL = ecbURL
+		}
+		return &ecb{url: o.URL, client: client}, nil
+	case ProviderFile:
+		if o.URL == "" {
+			return nil, errors.New("file provider needs a file path")
+		}
+		return &file{path: o.URL}, nil
+	case ProviderCoinGecko:
+		if o.URL == "" {
+			o.URL = coinGeckoURL
+		}
+		return &coinGecko{url: o.URL, client: client}, nil
+	case ProviderGoldAPI:
+		if o.URL == "" {
+			o.URL = goldAPIURL
+		}
+		return &goldAPI{url: o.URL, client: client}, nil
+	}
+
+	return nil, fmt.Errorf("unknown fx provider: %s", o.Type)
+}
+
+// erAPI fetches rates from the open.er-api.com JSON API.
+type erAPI struct {
+	url    string
+	client *http.Client
+}
+
+func (p *erAPI) name() string {
+	return ProviderERAPI
+}
+
+func (p *erAPI) fetch() (data, error) {
+	body, err := httpGet(p.client, p.url)
+	if err != nil {
+		return data{}, err
+	}
+
+	var out data
+	if err := json.Unmarshal(body, &out); err != nil {
+		return data{}, err
+	}
+
+	return out, nil
+}
+
+// ecb fetches the European Central Bank's daily XML reference rates
+// which are relative to EUR.
+type ecb struct {
+	url    string
+	client *http.Client
+}
+
+type ecbData struct {
+	Cube struct {
+		Cube struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+func (p *ecb) name() string {
+	return ProviderECB
+}
+
+func (p *ecb) fetch() (data, error) {
+	body, err := httpGet(p.client, p.url)
+	if err != nil {
+		return data{}, err
+	}
+
+	return parseECB(body)
+}
+
+func parseECB(b []byte) (data, error) {
+	var d ecbData
+	if err := xml.Unmarshal(b, &d); err != nil {
+		return data{}, err
+	}
+
+	date, err := time.Parse(dateFormat, d.Cube.Cube.Time)
+	if err != nil {
+		return data{}, fmt.Errorf("invalid ECB date: %v", err)
+	}
+
+	out := data{
+		Base:  "EUR",
+		Date:  date.Format(time.RFC1123Z),
+		Rates: map[string]float64{"EUR": 1},
+	}
+	for _, r := range d.Cube.Cube.Rates {
+		out.Rates[r.Currency] = r.Rate
+	}
+
+	return out, nil
+}
+
+// file reads rates from a local JSON or CSV file for air-gapped deployments.
+// JSON files have the same structure as the open.er-api.com response.
+// CSV files have CODE,rate rows where the base currency has the rate 1.
+type file struct {
+	path string
+}
+
+func (p *file) name() string {
+	return ProviderFile
+}
+
+func (p *file) fetch() (data, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return data{}, err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return data{}, err
+	}
+
+	var out data
+	if strings.ToLower(filepath.Ext(p.path)) == ".csv" {
+		out, err = parseCSV(f)
+	} else {
+		err = json.NewDecoder(f).Decode(&out)
+	}
+	if err != nil {
+		return data{}, err
+	}
+
+	// Use the file's modification time if the file doesn't carry one.
+	if out.Date == "" {
+		out.Date = st.ModTime().UTC().Format(time.RFC1123Z)
+	}
+
+	return out, nil
+}
+
+func parseCSV(r io.Reader) (data, error) {
+	rd := csv.NewReader(r)
+	rd.FieldsPerRecord = 2
+	rd.TrimLeadingSpace = true
+	rd.Comment = '#'
+
+	out := data{Rates: make(map[string]float64)}
+	for {
+		rec, err := rd.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return data{}, err
+		}
+
+		code := strings.ToUpper(strings.TrimSpace(rec[0]))
+		v, err := strconv.ParseFloat(strings.TrimSpace(rec[1]), 64)
+		if err != nil {
+			return data{}, fmt.Errorf("invalid rate for %s: %v", code, err)
+		}
+
+		out.Rates[code] = v
+		if v == 1 && out.Base == "" {
+			out.Base = code
+		}
+	}
+
+	if out.Base == "" {
+		return data{}, errors.New("no base currency (rate = 1) in CSV")
+	}
+
+	return out, nil
+}
+
+// coinGecko fetches crypto currency prices in USD from the CoinGecko API.
+type coinGecko struct {
+	url    string
+	client *http.Client
+}
+
+func (p *coinGecko) name() string {
+	return ProviderCoinGecko
+}
+
+func (p *coinGecko) fetch() (data, error) {
+	var (
+		ids  = make([]string, 0, len(coinGeckoIDs))
+		syms = make(map[string]string, len(coinGeckoIDs))
+	)
+	for sym, id := range coinGeckoIDs {
+		ids = append(ids, id)
+		syms[id] = sym
+	}
+	sort.Strings(ids)
+
+	body, err := httpGet(p.client, p.url+"?vs_currencies=usd&ids="+strings.Join(ids, ","))
+	if err != nil {
+		return data{}, err
+	}
+
+	// { "bitcoin": { "usd": 64000 } }
+	var res map[string]map[string]float64
+	if err := json.Unmarshal(body, &res); err != nil {
+		return data{}, err
+	}
+
+	out := data{
+		Base:  "USD",
+		Date:  time.Now().UTC().Format(time.RFC1123Z),
+		Rates: map[string]float64{"USD": 1},
+	}
+	for id, v := range res {
+		sym, ok := syms[id]
+		if !ok || v["usd"] <= 0 {
+			continue
+		}
+
+		// Rates are units per 1 USD.
+		out.Rates[sym] = 1 / v["usd"]
+	}
+
+	return out, nil
+}
+
+// goldAPI fetches precious metal spot prices in USD from gold-api.com.
+type goldAPI struct {
+	url    string
+	client *http.Client
+}
+
+func (p *goldAPI) name() string {
+	return ProviderGoldAPI
+}
+
+func (p *goldAPI) fetch() (data, error) {
+	out := data{
+		Base:  "USD",
+		Date:  time.Now().UTC().Format(time.RFC1123Z),
+		Rates: map[string]float64{"USD": 1},
+	}
+
+	for _, sym := range metals {
+		body, err := httpGet(p.client, p.url+"/"+sym)
+		if err != nil {
+			return data{}, err
+		}
+
+		var res struct {
+			Price float64 `json:"price"`
+		}
+		if err := json.Unmarshal(body, &res); err != nil {
+			return data{}, err
+		}
+		if res.Price <= 0 {
+			continue
+		}
+
+		out.Rates[sym] = 1 / res.Price
+	}
+
+	return out, nil
+}
+
+func httpGet(client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed: %v", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// crossCheck merges rates from multiple providers, the first being
+// the primary. Rates from other providers are rebased to the primary's
+// base currency. If a primary rate deviates from the median of all the
+// providers' rates by more than maxDev (fraction), it's replaced by the
+// median when there are three or more sources, or dropped otherwise, so
+// that a single bad feed can't publish absurd rates. Currencies missing
+// in the primary are filled in from the others.
+func crossCheck(list []data, maxDev float64) data {
+	primary := list[0]
+	if len(list) == 1 {
+		return primary
+	}
+
+	out := data{
+		Base:  primary.Base,
+		Date:  primary.Date,
+		Rates: make(map[string]float64, len(primary.Rates)),
+	}
+
+	// Collect all the rates per currency, rebased to the primary's base.
+	all := make(map[string][]float64)
+	for _, d := range list {
+		base, ok := d.Rates[primary.Base]
+		if !ok || base == 0 {
+			continue
+		}
+
+		for c, r := range d.Rates {
+			all[c] = append(all[c], r/base)
+		}
+	}
+
+	var rejected []string
+	for c, vals := range all {
+		med := median(vals)
+
+		r, ok := primary.Rates[c]
+		if !ok {
+			// Not in the primary. Take the rate from the others.
+			out.Rates[c] = med
+			continue
+		}
+
+		if len(vals) < 2 || maxDev <= 0 || c == primary.Base {
+			out.Rates[c] = r
+			continue
+		}
+
+		if dev := math.Abs(r-med) / med; dev <= maxDev {
+			out.Rates[c] = r
+			continue
+		}
+
+		rejected = append(rejected, c)
+		if len(vals) >= 3 {
+			out.Rates[c] = med
+		}
+	}
+
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		log.Printf("fx rates deviate across providers for: %s", strings.Join(rejected, ", "))
+	}
+
+	return out
+}
+
+func median(vals []float64) float64 {
+	v := make([]float64, len(vals))
+	copy(v, vals)
+	sort.Float64s(v)
+
+	n := len(v)
+	if n%2 == 1 {
+		return v[n/2]
+	}
+	return (v[n/2-1] + v[n/2]) / 2
+}
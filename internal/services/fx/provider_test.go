@@ -0,0 +1,125 @@
+package fx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ecbFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2024-03-01">
+			<Cube currency="USD" rate="1.0808"/>
+			<Cube currency="JPY" rate="162.22"/>
+			<Cube currency="INR" rate="89.5765"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func TestProviders(t *testing.T) {
+	dir := t.TempDir()
+
+	var (
+		jsonFile = filepath.Join(dir, "rates.json")
+		csvFile  = filepath.Join(dir, "rates.csv")
+	)
+	os.WriteFile(jsonFile, []byte(`{"base_code": "USD", "time_last_update_utc": "Fri, 01 Mar 2024 00:02:31 +0000",
+		"rates": {"USD": 1, "EUR": 0.925, "INR": 82.9, "JPY": 150.1, "GBP": 0.79}}`), 0644)
+	os.WriteFile(csvFile, []byte("# code,rate\nUSD,1\nEUR,0.926\nINR,8290\nJPY,150.0\n"), 0644)
+
+	p, _ := newProvider(ProviderOpt{Type: ProviderFile, URL: jsonFile}, 0)
+	j, err := p.fetch()
+	if err != nil || j.Base != "USD" || j.Rates["INR"] != 82.9 {
+		t.Fatalf("error reading JSON rates: %v %v", j, err)
+	}
+
+	p, _ = newProvider(ProviderOpt{Type: ProviderFile, URL: csvFile}, 0)
+	c, err := p.fetch()
+	if err != nil || c.Base != "USD" || c.Rates["JPY"] != 150 || c.Date == "" {
+		t.Fatalf("error reading CSV rates: %v %v", c, err)
+	}
+
+	e, err := parseECB([]byte(ecbFixture))
+	if err != nil || e.Base != "EUR" || e.Rates["EUR"] != 1 || e.Rates["INR"] != 89.5765 {
+		t.Fatalf("error parsing ECB rates: %v %v", e, err)
+	}
+
+	if _, err := newProvider(ProviderOpt{Type: "nope"}, 0); err == nil {
+		t.Errorf("expected error for unknown provider")
+	}
+
+	// Two sources. The bad INR rate in the CSV can't be resolved and is dropped.
+	out := crossCheck([]data{j, c}, 0.05)
+	if _, ok := out.Rates["INR"]; ok {
+		t.Errorf("expected deviating INR to be dropped: %v", out.Rates["INR"])
+	}
+	if out.Rates["JPY"] != 150.1 || out.Rates["GBP"] != 0.79 {
+		t.Errorf("unexpected cross-checked rates: %v", out.Rates)
+	}
+
+	// Three sources. The bad primary INR rate is replaced by the median.
+	out = crossCheck([]data{c, j, e}, 0.05)
+	if r := out.Rates["INR"]; r < 82 || r > 84 {
+		t.Errorf("expected INR to be replaced by the median, got %v", r)
+	}
+
+	// Currencies missing in the primary are filled in.
+	if r := out.Rates["GBP"]; r != 0.79 {
+		t.Errorf("expected GBP from the secondary, got %v", r)
+	}
+}
+
+func TestCrypto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crypto.csv")
+	os.WriteFile(path, []byte("USD,1\nBTC,0.00002\nXAU,0.0005\nUSDT,1.0\n"), 0644)
+
+	p, _ := newProvider(ProviderOpt{Type: ProviderFile, URL: path}, 0)
+	c, err := fetchAll([]provider{p}, 0)
+	if err != nil {
+		t.Fatalf("error fetching crypto fixture: %v", err)
+	}
+
+	fx := &FX{
+		opt: Opt{CryptoMaxAge: time.Hour},
+		data: data{
+			Base:  "EUR",
+			Date:  "fiat",
+			Rates: map[string]float64{"EUR": 1, "USD": 1.25, "INR": 100},
+		},
+		crypto:   c,
+		cryptoAt: time.Now(),
+	}
+
+	for q, want := range map[string]string{
+		"0.5BTC-EUR":    "0.50 BTC = 20000.00 EUR",
+		"1XAU-INR":      "1.00 XAU = 160000.00 INR",
+		"100EUR-BTC":    "100.00 EUR = 0.0025 BTC",
+		"1000USD-BTC":   "1000.00 USD = 0.02 BTC",
+		"781.25USD-BTC": "781.25 USD = 0.015625 BTC",
+		"1234USD-BTC":   "1234.00 USD = 0.02468 BTC",
+		"10USDT-USD":    "10.00 USDT = 10.00 USD",
+		"100EUR-INR":    "100.00 EUR = 10000.00 INR",
+	} {
+		out, err := fx.Query(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+		if !strings.Contains(out[0], want) {
+			t.Errorf("%s: want %q got %s", q, want, out[0])
+		}
+	}
+
+	// Stale crypto rates are refused but fiat conversions still work.
+	fx.cryptoAt = time.Now().Add(-time.Hour * 2)
+	if _, err := fx.Query("1BTC-EUR"); err == nil || !strings.Contains(err.Error(), "stale") {
+		t.Errorf("expected stale error, got %v", err)
+	}
+	if _, err := fx.Query("1USD-EUR"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
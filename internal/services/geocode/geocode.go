@@ -0,0 +1,74 @@
+// Package geocode returns the cities nearest to a lat,lng coordinate.
+package geocode
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+// TTL is set to 1 day (60*60*24 = 86,400) as city locations rarely change.
+const TTL = 86400
+
+var reLatLng = regexp.MustCompile(`^(-?\d+(?:\.\d+)?),(-?\d+(?:\.\d+)?)$`)
+
+// Geocode is the reverse geocoding controller.
+type Geocode struct {
+	opt Opt
+	geo *geo.Geo
+}
+
+// Opt contains config options for Geocode.
+type Opt struct {
+	// Max number of nearest cities to return.
+	MaxResults int
+}
+
+// New returns a new instance of Geocode.
+func New(o Opt, g *geo.Geo) *Geocode {
+	if o.MaxResults < 1 {
+		o.MaxResults = 3
+	}
+
+	return &Geocode{
+		opt: o,
+		geo: g,
+	}
+}
+
+// Query returns the cities nearest to a lat,lng coordinate, eg: 12.97,77.59.
+func (g *Geocode) Query(q string) ([]string, error) {
+	m := reLatLng.FindStringSubmatch(q)
+	if m == nil {
+		return nil, errors.New("invalid lat,lng format.")
+	}
+
+	lat, _ := strconv.ParseFloat(m[1], 64)
+	lng, _ := strconv.ParseFloat(m[2], 64)
+	if math.Abs(lat) > 90 || math.Abs(lng) > 180 {
+		return nil, errors.New("lat,lng out of bounds.")
+	}
+
+	locs := g.geo.Nearest(lat, lng, g.opt.MaxResults)
+	if len(locs) == 0 {
+		return nil, errors.New("no cities found.")
+	}
+
+	out := make([]string, 0, len(locs))
+	for _, l := range locs {
+		r := fmt.Sprintf(`%s %d TXT "%s, %s" "distance = %.1f KM" "timezone = %s" "population = %d"`,
+			q, TTL, l.Name, l.Place(), l.Distance, l.Timezone, l.Population)
+		out = append(out, r)
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (g *Geocode) Dump() ([]byte, error) {
+	return nil, nil
+}
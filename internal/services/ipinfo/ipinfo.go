@@ -0,0 +1,207 @@
+// package ipinfo classifies IP addresses per the IANA special-purpose
+// address registries and returns their other representations.
+package ipinfo
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+)
+
+type IPInfo struct{}
+
+// New returns a new instance of IPInfo.
+func New() *IPInfo {
+	return &IPInfo{}
+}
+
+const (
+	// TTL is set to 900 seconds (15 minutes).
+	TTL = 900
+
+	// maxSize is the max size of a response in bytes. The server only
+	// responds over UDP without compression or truncation, so responses
+	// have to fit in 512 byte messages.
+	maxSize = 512
+)
+
+// block is an address block in the IANA special-purpose address registries.
+type block struct {
+	Prefix netip.Prefix `json:"prefix"`
+
+	// Class is one of public, private, loopback, cgnat, documentation,
+	// multicast, link-local or reserved.
+	Class string `json:"class"`
+	Name  string `json:"name"`
+	RFC   string `json:"rfc"`
+}
+
+//go:embed registry.json
+var registryData []byte
+
+// registry is the embedded table of special-purpose address blocks. The
+// catch-all public and reserved blocks (0.0.0.0/0, ::/0, 2000::/3) apply
+// to the addresses that aren't in more specific blocks.
+var registry = loadRegistry(registryData)
+
+// 6to4 addresses embed the IPv4 address after the prefix.
+var prefix6to4 = netip.MustParsePrefix("2002::/16")
+
+func loadRegistry(b []byte) []block {
+	var out []block
+	if err := json.Unmarshal(b, &out); err != nil {
+		panic(fmt.Sprintf("error loading ip registry: %v", err))
+	}
+
+	return out
+}
+
+// Query parses an IPv4 or IPv6 address and returns its classification,
+// representations, reverse DNS name and embedded addresses.
+func (n *IPInfo) Query(q string) ([]string, error) {
+	ip, err := netip.ParseAddr(q)
+	if err != nil || ip.Zone() != "" {
+		return nil, errors.New("invalid ip.")
+	}
+
+	b := classify(ip)
+	class := []string{b.Class, b.Name}
+	if b.Prefix.Bits() > 0 {
+		class = append(class, b.Prefix.String())
+	}
+	if b.RFC != "" {
+		class = append(class, b.RFC)
+	}
+
+	var (
+		repr = []string{
+			"int " + new(big.Int).SetBytes(ip.AsSlice()).String(),
+			fmt.Sprintf("hex 0x%x", ip.AsSlice()),
+			"bin " + binary(ip),
+		}
+		recs = [][]string{class, repr, {"ptr " + reverse(ip)}}
+	)
+
+	// Embeddings of IPv4 addresses in IPv6 addresses and vice versa.
+	var emb []string
+	switch {
+	case ip.Is4():
+		b := ip.As4()
+		emb = append(emb,
+			"ipv4-mapped "+netip.AddrFrom16(ip.As16()).String(),
+			fmt.Sprintf("6to4 2002:%x:%x::/48", uint16(b[0])<<8|uint16(b[1]), uint16(b[2])<<8|uint16(b[3])))
+
+	case ip.Is4In6():
+		v4 := ip.Unmap()
+		emb = append(emb, fmt.Sprintf("ipv4-mapped %s (%s)", v4, classify(v4).Class))
+
+	case prefix6to4.Contains(ip):
+		b := ip.As16()
+		v4 := netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]})
+		emb = append(emb, fmt.Sprintf("6to4 %s (%s)", v4, classify(v4).Class))
+	}
+	if len(emb) > 0 {
+		recs = append(recs, emb)
+	}
+
+	size := msgSize(q)
+	for _, r := range recs {
+		size += recordSize(q, r...)
+	}
+
+	// The binary and then the int forms of long IPv6 addresses are left
+	// out so that the response fits.
+	if size > maxSize {
+		size -= 1 + len(repr[2])
+		recs[1] = repr[:2]
+	}
+	if size > maxSize {
+		recs[1] = repr[1:2]
+	}
+
+	out := make([]string, 0, len(recs))
+	for _, r := range recs {
+		out = append(out, fmt.Sprintf("%s %d TXT \"%s\"", q, TTL, strings.Join(r, "\" \"")))
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (n *IPInfo) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// msgSize returns the size of a response message for a query without
+// any records, that is, the header and the question.
+func msgSize(q string) int {
+	// The question is $q.ipinfo. followed by its type and class.
+	return 12 + len(q) + len(".ipinfo.") + 1 + 4
+}
+
+// recordSize returns the uncompressed size of a TXT record for a query
+// with the given strings.
+func recordSize(q string, txt ...string) int {
+	// The name is $q. followed by the type, class, TTL, and data length.
+	n := len(q) + 2 + 10
+	for _, s := range txt {
+		n += 1 + len(s)
+	}
+
+	return n
+}
+
+// classify returns the most specific registry block of an IP.
+func classify(ip netip.Addr) block {
+	var out block
+	for _, b := range registry {
+		if b.Prefix.Contains(ip) && (!out.Prefix.IsValid() || b.Prefix.Bits() > out.Prefix.Bits()) {
+			out = b
+		}
+	}
+
+	return out
+}
+
+// binary returns the bits of an IP grouped by octets for IPv4 and by
+// hextets for IPv6, eg: 00001010.00000000.00000000.00000001.
+func binary(ip netip.Addr) string {
+	var (
+		b   = ip.AsSlice()
+		out = make([]string, 0, len(b))
+	)
+	if ip.Is4() {
+		for _, o := range b {
+			out = append(out, fmt.Sprintf("%08b", o))
+		}
+		return strings.Join(out, ".")
+	}
+
+	for i := 0; i < len(b); i += 2 {
+		out = append(out, fmt.Sprintf("%08b%08b", b[i], b[i+1]))
+	}
+	return strings.Join(out, ":")
+}
+
+// reverse returns the reverse DNS name of an IP, eg: 1.0.0.10.in-addr.arpa.
+func reverse(ip netip.Addr) string {
+	var (
+		b   = ip.AsSlice()
+		out []string
+	)
+	if ip.Is4() {
+		for i := len(b) - 1; i >= 0; i-- {
+			out = append(out, fmt.Sprintf("%d", b[i]))
+		}
+		return strings.Join(out, ".") + ".in-addr.arpa."
+	}
+
+	for i := len(b) - 1; i >= 0; i-- {
+		out = append(out, fmt.Sprintf("%x.%x", b[i]&0xf, b[i]>>4))
+	}
+	return strings.Join(out, ".") + ".ip6.arpa."
+}
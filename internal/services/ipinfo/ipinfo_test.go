@@ -0,0 +1,130 @@
+package ipinfo
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestQuery(t *testing.T) {
+	n := New()
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"8.8.8.8", []string{
+			`8.8.8.8 900 TXT "public" "Public"`,
+			`8.8.8.8 900 TXT "int 134744072" "hex 0x08080808" "bin 00001000.00001000.00001000.00001000"`,
+			`8.8.8.8 900 TXT "ptr 8.8.8.8.in-addr.arpa."`,
+			`8.8.8.8 900 TXT "ipv4-mapped ::ffff:8.8.8.8" "6to4 2002:808:808::/48"`,
+		}},
+		{"100.64.1.2", []string{
+			`100.64.1.2 900 TXT "cgnat" "Shared Address Space" "100.64.0.0/10" "RFC 6598"`,
+			`100.64.1.2 900 TXT "int 1681916162" "hex 0x64400102" "bin 01100100.01000000.00000001.00000010"`,
+			`100.64.1.2 900 TXT "ptr 2.1.64.100.in-addr.arpa."`,
+			`100.64.1.2 900 TXT "ipv4-mapped ::ffff:100.64.1.2" "6to4 2002:6440:102::/48"`,
+		}},
+		{"2001:db8::1", []string{
+			`2001:db8::1 900 TXT "documentation" "Documentation" "2001:db8::/32" "RFC 3849"`,
+			`2001:db8::1 900 TXT "int 42540766411282592856903984951653826561" "hex 0x20010db8000000000000000000000001" "bin 0010000000000001:0000110110111000:0000000000000000:0000000000000000:0000000000000000:0000000000000000:0000000000000000:0000000000000001"`,
+			`2001:db8::1 900 TXT "ptr 1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."`,
+		}},
+	}
+	for _, tc := range tests {
+		out, err := n.Query(tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.q, err)
+			continue
+		}
+		if !slices.Equal(out, tc.want) {
+			t.Errorf("%s: want %q got %q", tc.q, tc.want, out)
+		}
+		for _, r := range out {
+			if _, err := dns.NewRR(r); err != nil {
+				t.Errorf("%s: invalid RR: %v", r, err)
+			}
+		}
+	}
+
+	// Classes and embeddings.
+	for q, want := range map[string]string{
+		"10.1.2.3":        "private",
+		"192.168.1.1":     "private",
+		"127.0.0.1":       "loopback",
+		"169.254.1.1":     "link-local",
+		"192.0.2.1":       "documentation",
+		"224.0.0.251":     "multicast",
+		"240.0.0.1":       "reserved",
+		"0.0.0.0":         "reserved",
+		"::1":             "loopback",
+		"fd00::1":         "private",
+		"fe80::1":         "link-local",
+		"ff02::1":         "multicast",
+		"2606:4700::1111": "public",
+		"4000::1":         "reserved",
+	} {
+		out, err := n.Query(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+		if c := classify(netip.MustParseAddr(q)).Class; c != want {
+			t.Errorf("%s: want %s got %s (%s)", q, want, c, out[0])
+		}
+	}
+
+	out, _ := n.Query("::ffff:10.0.0.1")
+	if out[len(out)-1] != `::ffff:10.0.0.1 900 TXT "ipv4-mapped 10.0.0.1 (private)"` {
+		t.Errorf("unexpected ipv4-mapped embedding: %s", out[len(out)-1])
+	}
+	out, _ = n.Query("2002:808:808::1")
+	if out[len(out)-1] != `2002:808:808::1 900 TXT "6to4 8.8.8.8 (public)"` {
+		t.Errorf("unexpected 6to4 embedding: %s", out[len(out)-1])
+	}
+
+	for _, q := range []string{"", "ipinfo.", "1.2.3", "fe80::1%eth0"} {
+		if _, err := n.Query(q); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+}
+
+func TestResponseSize(t *testing.T) {
+	n := New()
+
+	// Answers should fit in a 512 byte UDP message without compression,
+	// including the longest forms of IPv6 addresses.
+	for _, q := range []string{
+		"255.255.255.255",
+		"2001:db8::1",
+		"2606:4700:4700:1111:2606:4700:4700:1111",
+		"2002:c000:0204:ffff:ffff:ffff:ffff:ffff",
+		"0000:0000:0000:0000:0000:ffff:255.255.255.255",
+	} {
+		out, err := n.Query(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+
+		m := &dns.Msg{}
+		m.SetQuestion(dns.Fqdn(q+".ipinfo"), dns.TypeTXT)
+		for _, r := range out {
+			rr, err := dns.NewRR(r)
+			if err != nil {
+				t.Fatalf("%s: invalid RR: %v", r, err)
+			}
+			m.Answer = append(m.Answer, rr)
+		}
+		if n := m.Len(); n > 512 {
+			t.Errorf("%s: response is %d bytes", q, n)
+		}
+		if !strings.Contains(out[1], `"hex 0x`) {
+			t.Errorf("%s: hex form left out: %s", q, out[1])
+		}
+	}
+}
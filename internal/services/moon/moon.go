@@ -0,0 +1,167 @@
+// Package moon returns the lunar phase, illumination, age, the next new
+// and full moons, and moonrise and moonset for cities computed locally.
+package moon
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/astro"
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+const (
+	// TTL is set to 1 hour (60*60=3,600) as the phase changes slowly.
+	TTL = 3600
+
+	// DateTTL is set to 1 day (60*60*24 = 86,400) for answers for a given date.
+	DateTTL = 86400
+
+	dateFormat = "2006-01-02"
+
+	// maxResults is the max number of cities returned for an ambiguous name.
+	maxResults = 3
+)
+
+var reDate = regexp.MustCompile(`^(\d{4}\-\d{2}\-\d{2})(?:\.(.+))?$`)
+
+// Phase names for each 45 degree sector of the elongation centred on the
+// principal phases.
+var phases = []string{
+	"New Moon",
+	"Waxing Crescent",
+	"First Quarter",
+	"Waxing Gibbous",
+	"Full Moon",
+	"Waning Gibbous",
+	"Last Quarter",
+	"Waning Crescent",
+}
+
+// Moon is the lunar phase and moonrise/moonset controller.
+type Moon struct {
+	geo *geo.Geo
+}
+
+// New returns a new instance of Moon.
+func New(g *geo.Geo) *Moon {
+	return &Moon{geo: g}
+}
+
+// Query returns the moon's phase now or on a date, and with a city, the
+// moonrise and moonset in the city, eg: moon, 2025-06-21.moon, berlin.moon,
+// 2025-06-21.berlin.moon.
+func (m *Moon) Query(q string) ([]string, error) {
+	var (
+		city = q
+		date = ""
+		ttl  = TTL
+	)
+	if q == "moon." {
+		city = ""
+	}
+	if r := reDate.FindStringSubmatch(q); r != nil {
+		date, city = r[1], r[2]
+		ttl = DateTTL
+	}
+
+	// Phase only, in UTC.
+	if city == "" {
+		day, err := parseDate(date, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+
+		at := time.Now().UTC()
+		if date != "" {
+			at = day.Add(12 * time.Hour)
+		}
+
+		return []string{fmt.Sprintf(`%s %d TXT "%s"`, q, ttl, strings.Join(phase(at), `" "`))}, nil
+	}
+
+	locs := m.geo.Query(city)
+	if locs == nil {
+		return nil, m.geo.NotFound(city)
+	}
+
+	out := make([]string, 0, len(locs))
+	for n, l := range locs {
+		if n == maxResults {
+			break
+		}
+
+		day, err := parseDate(date, l.Loc)
+		if err != nil {
+			return nil, err
+		}
+
+		at := time.Now().In(l.Loc)
+		if date != "" {
+			at = day.Add(12 * time.Hour)
+		}
+
+		var (
+			rise, set = astro.MoonRiseSet(day, l.Lat, l.Lon)
+			ev        = []string{day.Format("Mon, 02 Jan 2006 MST"), riseSet("moonrise", rise, l.Loc), riseSet("moonset", set, l.Loc)}
+		)
+
+		out = append(out, fmt.Sprintf(`%s %d TXT "%s (%s, %s)" "%s" "%s"`,
+			q, ttl, l.Name, l.Timezone, l.Place(), strings.Join(ev, `" "`), strings.Join(phase(at), `" "`)))
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (m *Moon) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// phase returns the formatted phase, illumination, age, and the next new
+// and full moons at a time, in the time's location.
+func phase(at time.Time) []string {
+	var (
+		jd   = astro.JulianDay(at)
+		elon = astro.MoonElongation(jd)
+		name = phases[int((elon+22.5)/45)%len(phases)]
+
+		prevNew = astro.MoonPhase(at, 0, true)
+		nextNew = astro.MoonPhase(at, 0, false)
+		full    = astro.MoonPhase(at, 180, false)
+	)
+
+	return []string{
+		name,
+		fmt.Sprintf("illumination = %.0f%%", astro.MoonIllumination(jd)*100),
+		fmt.Sprintf("age = %.1f days", at.Sub(prevNew).Hours()/24),
+		"next new moon = " + nextNew.In(at.Location()).Format("2006-01-02 15:04 MST"),
+		"next full moon = " + full.In(at.Location()).Format("2006-01-02 15:04 MST"),
+	}
+}
+
+// riseSet formats a moonrise or moonset time.
+func riseSet(name string, t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return "no " + name
+	}
+
+	return name + " = " + t.In(loc).Format("15:04")
+}
+
+// parseDate parses an optional date to the start of the day in a location,
+// defaulting to today.
+func parseDate(date string, loc *time.Location) (time.Time, error) {
+	day := time.Now().In(loc)
+	if date != "" {
+		d, err := time.ParseInLocation(dateFormat, date, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date. Use %s.", dateFormat)
+		}
+		day = d
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc), nil
+}
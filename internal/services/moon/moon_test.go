@@ -0,0 +1,63 @@
+package moon
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/geo/geotest"
+)
+
+func TestQuery(t *testing.T) {
+	m := New(geotest.New(t, geotest.Berlin))
+
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"2024-04-08", `2024-04-08 86400 TXT "New Moon" "illumination = 0%" "age = 29.1 days" ` +
+			`"next new moon = 2024-04-08 18:22 UTC" "next full moon = 2024-04-23 23:50 UTC"`},
+		{"2024-04-23.berlin", `2024-04-23.berlin 86400 TXT "Berlin (Europe/Berlin, DE)" "Tue, 23 Apr 2024 CEST" "moonrise = 20:04" "moonset = 05:33" ` +
+			`"Full Moon" "illumination = 100%" "age = 14.7 days" "next new moon = 2024-05-08 05:24 CEST" "next full moon = 2024-04-24 01:50 CEST"`},
+		{"2024-04-15.berlin/de", `2024-04-15.berlin/de 86400 TXT "Berlin (Europe/Berlin, DE)" "Mon, 15 Apr 2024 CEST" "moonrise = 10:17" "moonset = 03:39" ` +
+			`"First Quarter" "illumination = 46%" "age = 6.7 days" "next new moon = 2024-05-08 05:24 CEST" "next full moon = 2024-04-24 01:50 CEST"`},
+	}
+	for _, tc := range tests {
+		out, err := m.Query(tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.q, err)
+			continue
+		}
+		if len(out) != 1 || out[0] != tc.want {
+			t.Errorf("%s: want %s got %s", tc.q, tc.want, out)
+		}
+	}
+
+	// Today's answers are cached for less time.
+	for _, q := range []string{"moon.", "berlin"} {
+		if out, err := m.Query(q); err != nil || !strings.HasPrefix(out[0], q+" 3600 TXT ") {
+			t.Errorf("%s: unexpected answer for today: %v %v", q, out, err)
+		}
+	}
+
+	for q, want := range map[string]string{
+		"2024-02-30":        "invalid date",
+		"2024-02-30.berlin": "invalid date",
+		"berln":             "unknown city. Did you mean berlin?",
+	} {
+		if _, err := m.Query(q); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: want error %q got %v", q, want, err)
+		}
+	}
+}
+
+func TestRiseSet(t *testing.T) {
+	loc, _ := time.LoadLocation("Europe/Berlin")
+
+	if s := riseSet("moonset", time.Time{}, loc); s != "no moonset" {
+		t.Errorf("unexpected missing event: %s", s)
+	}
+	if s := riseSet("moonrise", time.Date(2024, 4, 23, 18, 4, 0, 0, time.UTC), loc); s != "moonrise = 20:04" {
+		t.Errorf("unexpected event: %s", s)
+	}
+}
@@ -1,185 +1,537 @@
+// Package sky tracks satellites with the SGP4 model from two-line element
+// sets (TLE) that are loaded from a local file and optionally refreshed
+// periodically from Celestrak.
 package sky
 
 import (
-	"encoding/json"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"golang.org/x/time/rate"
+	"github.com/knadh/dns.toys/internal/astro"
+	"github.com/knadh/dns.toys/internal/geo"
 )
 
 const (
-	apiURL = "https://api.n2yo.com/rest/v1/satellite/positions/%s/0/0/0/1/&apiKey=%s"
+	// TTL is set to 10 seconds as satellites move several kilometers a second.
+	TTL = 10
 
-	// Max requests/sec allowed by the API.
-	apiRateLimit = 900
+	// PassTTL is set to 5 minutes (60*5=300) for pass predictions.
+	PassTTL = 300
 
-	// TTL is set to 1 hour (60*60=3,600).
-	TTL = 3600
+	// BodyTTL is set to 1 minute for the positions of the sun, moon, and planets.
+	BodyTTL = 60
+
+	// maxResults is the max number of satellites or cities returned for a name.
+	maxResults = 3
+
+	// maxSize is the max size of a response in bytes. The server only
+	// responds over UDP without compression or truncation, so responses
+	// have to fit in 512 byte messages.
+	maxSize = 512
+
+	// Passes in the next passDays days that reach minElevation degrees
+	// above the horizon are predicted, up to maxPasses.
+	passDays     = 3
+	minElevation = 10
+	maxPasses    = 5
 )
 
-type apiData struct {
-	Info struct {
-		SatName           string `json:"satname"`
-		SatID             int    `json:"satid"`
-		TransactionsCount int    `json:"transactionscount"`
-	} `json:"info"`
-	Positions []struct {
-		SatLatitude  float64 `json:"satlatitude"`
-		SatLongitude float64 `json:"satlongitude"`
-		SatAltitude  float64 `json:"sataltitude"`
-		Azimuth      float64 `json:"azimuth"`
-		Elevation    float64 `json:"elevation"`
-		RA           float64 `json:"ra"`
-		Dec          float64 `json:"dec"`
-		Timestamp    int64   `json:"timestamp"`
-		Eclipsed     bool    `json:"eclipsed"`
-	} `json:"positions"`
-}
-
-type entry struct {
-	ExpiresAt time.Time `json:"expires_at"`
-	Data      apiData   `json:"data"`
-}
-
-// Opt contains config options for Weather.
+var reNonAlnum = regexp.MustCompile(`[^a-z0-9]`)
+
+// Common names of popular satellites that aren't in their catalog names.
+var aliases = map[string]int{
+	"iss":      25544,
+	"hubble":   20580,
+	"tiangong": 48274,
+}
+
+// Opt contains config options for Sky.
 type Opt struct {
-	CacheTTL   time.Duration
-	ReqTimeout time.Duration
-	APIKey     string
+	// TLEFile is the path to the element sets in the Celestrak three line
+	// format. It's loaded on start and overwritten on every refresh.
+	TLEFile string
+
+	// Optional. TLEURL is fetched every RefreshInterval.
+	TLEURL          string
+	RefreshInterval time.Duration
+	ReqTimeout      time.Duration
 }
 
-type Sky struct {
-	data map[string]entry
+// catalog is a set of satellites indexed by their catalog numbers and names.
+type catalog struct {
+	sats  []*astro.Satellite
+	ids   map[int]int
+	names map[string][]int
+}
 
-	// Queue for defering API fetch requests.
-	fetchQueue chan string
+type Sky struct {
+	cat      *catalog
+	loadedAt time.Time
 
-	limiter *rate.Limiter
-	mut     sync.RWMutex
+	lastErrAt time.Time
+	mut       sync.RWMutex
 
 	opt    Opt
 	client *http.Client
+
+	// Optional. Names the city nearest to the position.
+	geo *geo.Geo
 }
 
-func New(o Opt) *Sky {
-	w := &Sky{
-		data:       make(map[string]entry),
-		fetchQueue: make(chan string, 1000),
+// New returns a new instance of Sky. If TLEURL is set, the element sets
+// are refreshed in the background.
+func New(o Opt, g *geo.Geo) (*Sky, error) {
+	if o.RefreshInterval == 0 {
+		o.RefreshInterval = 12 * time.Hour
+	}
+	if o.ReqTimeout == 0 {
+		o.ReqTimeout = 10 * time.Second
+	}
+
+	s := &Sky{
+		opt:    o,
+		geo:    g,
+		client: &http.Client{Timeout: o.ReqTimeout},
+	}
+
+	b, err := os.ReadFile(o.TLEFile)
+	if err != nil && (!os.IsNotExist(err) || o.TLEURL == "") {
+		return nil, fmt.Errorf("error reading TLE file: %v", err)
+	}
+	if err == nil {
+		if err := s.load(b); err != nil {
+			return nil, fmt.Errorf("error loading TLE file: %v", err)
+		}
+		if st, err := os.Stat(o.TLEFile); err == nil {
+			s.loadedAt = st.ModTime()
+		}
+		log.Printf("%d satellites loaded from %s", len(s.cat.sats), o.TLEFile)
+	}
 
-		// yr.no API request rate limit.
-		limiter: rate.NewLimiter(apiRateLimit, 1),
-		opt:     o,
-		client: &http.Client{
-			Timeout: o.ReqTimeout,
-			Transport: &http.Transport{
-				MaxIdleConnsPerHost:   apiRateLimit,
-				ResponseHeaderTimeout: o.ReqTimeout,
-			},
-		},
+	if o.TLEURL != "" {
+		go s.run()
 	}
 
-	return w
+	return s, nil
 }
 
-// Query queries the weather for a given location.
-func (w *Sky) Query(q string) ([]string, error) {
-	q = strings.ToLower(q)
-	// For now, just support ISS.
-	if q != "iss" {
-		return nil, errors.New("only `ISS` is supported")
+// Query returns the current position of satellites by their NORAD catalog
+// number or name, eg: 25544, iss, hubble, or the next passes of a satellite
+// over a city, eg: iss.berlin. The sun, moon, and planets are also supported,
+// eg: mars, jupiter.berlin.
+func (s *Sky) Query(q string) ([]string, error) {
+	name, city, isPass := strings.Cut(strings.ToLower(q), ".")
+
+	if b, ok := astro.ParseBody(name); ok {
+		return s.body(q, b, city)
 	}
 
-	d, err := w.get("25544") // 25544 is the N2YO ID for ISS.
+	sats, err := s.find(name)
 	if err != nil {
 		return nil, err
 	}
 
-	r := fmt.Sprintf(`%s %d TXT "%s" "lat=%v" "lon=%v" "altitude=%vKM" "azimuth=%v" "elevation=%v" "ra=%v" "time=%s"`,
-		q, d.Data.Info.SatID, d.Data.Info.SatName, d.Data.Positions[0].SatLatitude, d.Data.Positions[0].SatLongitude,
-		d.Data.Positions[0].SatAltitude, d.Data.Positions[0].Azimuth,
-		d.Data.Positions[0].Elevation, d.Data.Positions[0].RA, time.Unix(d.Data.Positions[0].Timestamp, 0).Format(time.RFC3339))
-	l := fmt.Sprintf(`%s %d TXT "https://maps.google.com/?q=%v,%v"`, q, d.Data.Info.SatID, d.Data.Positions[0].SatLatitude, d.Data.Positions[0].SatLongitude)
+	if isPass {
+		return s.passes(q, sats[0], city)
+	}
+
+	var (
+		now  = time.Now().UTC()
+		recs = make([][]string, 0, min(len(sats), maxResults))
+	)
+	for _, sat := range sats[:min(len(sats), maxResults)] {
+		pos, vel, err := sat.Position(now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", sat.Name, err)
+		}
+
+		var (
+			lat, lon, alt = astro.Geodetic(pos, now)
+			r             = math.Sqrt(pos[0]*pos[0] + pos[1]*pos[1] + pos[2]*pos[2])
+			ra            = math.Mod(math.Atan2(pos[1], pos[0])*180/math.Pi+360, 360)
+			dec           = math.Asin(pos[2]/r) * 180 / math.Pi
+			speed         = math.Sqrt(vel[0]*vel[0] + vel[1]*vel[1] + vel[2]*vel[2])
+		)
+
+		rec := []string{sat.Name, fmt.Sprintf("id=%d", sat.ID),
+			fmt.Sprintf("lat=%.4f", lat), fmt.Sprintf("lon=%.4f", lon),
+			fmt.Sprintf("altitude=%.1fKM", alt), fmt.Sprintf("velocity=%.2fKM/s", speed),
+			fmt.Sprintf("ra=%.2f", ra), fmt.Sprintf("dec=%.2f", dec), "time=" + now.Format(time.RFC3339)}
+
+		// Name the city nearest to the position on the ground.
+		if s.geo != nil {
+			if n := s.geo.Nearest(lat, lon, 1); len(n) > 0 {
+				rec = append(rec, "over near "+n[0].String())
+			}
+		}
+
+		recs = append(recs, append(rec, fmt.Sprintf("https://maps.google.com/?q=%.4f,%.4f", lat, lon)))
+	}
+
+	return fit(q, TTL, recs, func(n int) string {
+		return fmt.Sprintf("%d more satellites, dig by NORAD ID", n+len(sats)-len(recs))
+	}), nil
+}
+
+// passes returns the next passes of a satellite over a city in the city's
+// timezone.
+func (s *Sky) passes(q string, sat *astro.Satellite, city string) ([]string, error) {
+	if s.geo == nil {
+		return nil, errors.New("city lookups are not enabled.")
+	}
+
+	locs := s.geo.Query(city)
+	if locs == nil {
+		return nil, s.geo.NotFound(city)
+	}
+
+	var (
+		now  = time.Now()
+		recs [][]string
+	)
+	for n, l := range locs {
+		if n == maxResults {
+			break
+		}
+
+		ps, err := sat.Passes(now, now.Add(passDays*24*time.Hour), l.Lat, l.Lon, minElevation, maxPasses)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", sat.Name, err)
+		}
+
+		name := fmt.Sprintf("%s over %s (%s, %s)", sat.Name, l.Name, l.Timezone, l.Place())
+		if len(ps) == 0 {
+			recs = append(recs, []string{name, fmt.Sprintf("no passes above %d deg in the next %d days", minElevation, passDays)})
+			continue
+		}
+
+		for _, p := range ps {
+			recs = append(recs, []string{name,
+				"rise = " + p.Rise.In(l.Loc).Format("Mon 02 Jan 15:04:05 MST"),
+				fmt.Sprintf("max = %s (%.0f deg, %s)", p.Max.In(l.Loc).Format("15:04:05"), p.MaxElevation, compass(p.MaxAzimuth)),
+				"set = " + p.Set.In(l.Loc).Format("15:04:05"),
+				visibility(p, l.Lat, l.Lon)})
+		}
+	}
+
+	return fit(q, PassTTL, recs, func(n int) string {
+		return fmt.Sprintf("%d more passes not shown", n)
+	}), nil
+}
+
+// body returns the position of the sun, moon, or a planet, and with a city,
+// its altitude, azimuth, and rise and set times in the city's timezone.
+func (s *Sky) body(q string, b astro.Body, city string) ([]string, error) {
+	var (
+		now           = time.Now()
+		jd            = astro.JulianDay(now)
+		ra, dec       = b.Position(jd)
+		_, _, dist    = b.Ecliptic(jd)
+		constellation = b.Constellation(jd)
+		distance      = fmt.Sprintf("distance=%.3fAU", dist)
+	)
+	if b == astro.Moon {
+		distance = fmt.Sprintf("distance=%.0fKM", dist*astro.KMPerAU)
+	}
+
+	pos := []string{fmt.Sprintf("ra=%.2f", ra), fmt.Sprintf("dec=%.2f", dec), "constellation=" + constellation, distance}
+	if city == "" {
+		return []string{fmt.Sprintf(`%s %d TXT "%s" "%s"`, q, BodyTTL, b.Name(), strings.Join(pos, `" "`))}, nil
+	}
+
+	if s.geo == nil {
+		return nil, errors.New("city lookups are not enabled.")
+	}
+	locs := s.geo.Query(city)
+	if locs == nil {
+		return nil, s.geo.NotFound(city)
+	}
+
+	recs := make([][]string, 0, min(len(locs), maxResults))
+	for n, l := range locs {
+		if n == maxResults {
+			break
+		}
+
+		var (
+			alt, az   = astro.Horizontal(ra, dec, l.Lat, l.Lon, jd)
+			t         = now.In(l.Loc)
+			day       = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, l.Loc)
+			rise, set = b.RiseSet(day, l.Lat, l.Lon)
+		)
+
+		r := append([]string{fmt.Sprintf("%s from %s (%s, %s)", b.Name(), l.Name, l.Timezone, l.Place())}, pos...)
+		recs = append(recs, append(r,
+			fmt.Sprintf("altitude=%.1f", alt), fmt.Sprintf("azimuth=%.1f (%s)", az, compass(az)),
+			riseSet("rise", rise, l.Loc), riseSet("set", set, l.Loc)))
+	}
 
-	return []string{r, l}, nil
+	return fit(q, BodyTTL, recs, func(n int) string {
+		return fmt.Sprintf("%d more cities, add a country code, eg: %s/gb", n+len(locs)-len(recs), city)
+	}), nil
 }
 
-// Dump produces a gob dump of the cached data.
-func (w *Sky) Dump() ([]byte, error) {
+// Dump is not implemented in this package.
+func (s *Sky) Dump() ([]byte, error) {
 	return nil, nil
 }
 
-// Load loads a gob dump of cached data.
-func (w *Sky) Load(b []byte) error {
-	return nil
+// Health returns the number of satellites loaded and the age of the
+// element sets.
+func (s *Sky) Health() []string {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	if s.cat == nil {
+		return []string{"status = unavailable"}
+	}
+
+	out := []string{
+		"status = ok",
+		fmt.Sprintf("satellites = %d", len(s.cat.sats)),
+		"last success = " + s.loadedAt.UTC().Format(time.RFC3339),
+	}
+	if s.lastErrAt.After(s.loadedAt) {
+		out = append(out, "last error = "+s.lastErrAt.UTC().Format(time.RFC3339))
+	}
+
+	return out
 }
 
-func (w *Sky) get(q string) (entry, error) {
-	w.mut.RLock()
-	data, ok := w.data[q]
-	w.mut.RUnlock()
+// find returns the satellites matching a catalog number or a name.
+func (s *Sky) find(q string) ([]*astro.Satellite, error) {
+	s.mut.RLock()
+	cat := s.cat
+	s.mut.RUnlock()
 
-	if ok && data.ExpiresAt.After(time.Now()) {
-		return data, nil
+	if cat == nil {
+		return nil, errors.New("satellite data is not loaded yet.")
 	}
 
-	data, err := w.fetchAPI(q)
+	id, err := strconv.Atoi(q)
 	if err != nil {
-		return entry{}, err
+		id = aliases[q]
+	}
+	if n, ok := cat.ids[id]; ok {
+		return []*astro.Satellite{cat.sats[n]}, nil
 	}
 
-	w.mut.Lock()
-	w.data[q] = data
-	w.mut.Unlock()
+	var out []*astro.Satellite
+	for _, n := range cat.names[normalize(q)] {
+		out = append(out, cat.sats[n])
+	}
+	if len(out) == 0 {
+		return nil, errors.New("unknown satellite.")
+	}
+
+	return out, nil
+}
+
+// run periodically fetches the element sets from TLEURL.
+func (s *Sky) run() {
+	for {
+		if err := s.refresh(); err != nil {
+			log.Printf("error refreshing TLE: %v", err)
 
-	return data, nil
+			s.mut.Lock()
+			s.lastErrAt = time.Now()
+			s.mut.Unlock()
+
+			// Fetch failed. Retry again in a few minutes.
+			time.Sleep(5 * time.Minute)
+			continue
+		}
+
+		time.Sleep(s.opt.RefreshInterval)
+	}
 }
 
-func (w *Sky) fetchAPI(q string) (entry, error) {
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(apiURL, q, w.opt.APIKey), nil)
+// refresh fetches the element sets from TLEURL, loads them, and saves them
+// to TLEFile.
+func (s *Sky) refresh() error {
+	resp, err := s.client.Get(s.opt.TLEURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return entry{}, err
+		return err
+	}
+
+	if err := s.load(b); err != nil {
+		return err
 	}
 
-	r, err := w.client.Do(req)
+	s.mut.Lock()
+	s.loadedAt = time.Now()
+	n := len(s.cat.sats)
+	s.mut.Unlock()
+	log.Printf("%d satellites loaded from %s", n, s.opt.TLEURL)
+
+	// Write to a temp file and rename so that a crash doesn't leave a
+	// truncated file behind.
+	if s.opt.TLEFile == "" {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.opt.TLEFile), filepath.Base(s.opt.TLEFile)+".*")
 	if err != nil {
-		return entry{}, err
+		return err
 	}
-	defer func() {
-		// Drain and close the body to let the Transport reuse the connection
-		io.Copy(io.Discard, r.Body)
-		r.Body.Close()
-	}()
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.opt.TLEFile)
+}
 
-	var body []byte
-	defer r.Body.Close()
-	b, err := io.ReadAll(r.Body)
+// load parses element sets and replaces the catalog.
+func (s *Sky) load(b []byte) error {
+	sats, err := astro.ReadTLE(bytes.NewReader(b))
 	if err != nil {
-		return entry{}, err
+		return err
+	}
+	if len(sats) == 0 {
+		return errors.New("no satellites found")
+	}
+
+	cat := &catalog{
+		sats:  sats,
+		ids:   make(map[int]int, len(sats)),
+		names: make(map[string][]int, len(sats)),
+	}
+	for n, sat := range sats {
+		cat.ids[sat.ID] = n
+
+		// Index the full name and its parts, eg: ISS (ZARYA) as isszarya,
+		// iss, and zarya.
+		keys := []string{normalize(sat.Name)}
+		for _, p := range strings.FieldsFunc(sat.Name, func(r rune) bool { return r == '(' || r == ')' }) {
+			keys = append(keys, normalize(p))
+		}
+
+		seen := map[string]bool{}
+		for _, k := range keys {
+			if k == "" || seen[k] {
+				continue
+			}
+			seen[k] = true
+			cat.names[k] = append(cat.names[k], n)
+		}
 	}
 
-	body = b
+	s.mut.Lock()
+	s.cat = cat
+	s.mut.Unlock()
+
+	return nil
+}
 
-	// Even if the request failed, still cache the invalid request with a TTL
-	// so as to not bombard the API.
-	if r.StatusCode == http.StatusForbidden || r.StatusCode == http.StatusTooManyRequests {
-		return entry{}, errors.New("error fetching data.")
+// fit returns the TXT records for a query with the given strings, as many
+// as fit in a response. If some are left out, the last record is more(the
+// number left out).
+func fit(q string, ttl int, recs [][]string, more func(n int) string) []string {
+	var (
+		out     = make([]string, 0, len(recs)+1)
+		size    = msgSize(q)
+		reserve = recordSize(q, more(len(recs)))
+	)
+	for i, r := range recs {
+		n := recordSize(q, r...)
+		if i < len(recs)-1 {
+			n += reserve
+		}
+		if size+n > maxSize {
+			break
+		}
+
+		size += recordSize(q, r...)
+		out = append(out, fmt.Sprintf("%s %d TXT \"%s\"", q, ttl, strings.Join(r, `" "`)))
 	}
 
-	var data apiData
-	if err := json.Unmarshal(body, &data); err != nil {
-		return entry{}, err
+	if n := len(recs) - len(out); n > 0 {
+		out = append(out, fmt.Sprintf("%s %d TXT \"%s\"", q, ttl, more(n)))
 	}
 
-	out := entry{
-		ExpiresAt: time.Now().Add(w.opt.CacheTTL),
-		Data:      data,
+	return out
+}
+
+// msgSize returns the size of a response message for a query without
+// any records, that is, the header and the question.
+func msgSize(q string) int {
+	// The question is $q.sky. followed by its type and class.
+	return 12 + len(q) + len(".sky.") + 1 + 4
+}
+
+// recordSize returns the uncompressed size of a TXT record for a query
+// with the given strings.
+func recordSize(q string, txt ...string) int {
+	// The name is $q. followed by the type, class, TTL, and data length.
+	n := len(q) + 2 + 10
+	for _, s := range txt {
+		n += 1 + len(s)
 	}
 
-	return out, nil
+	return n
+}
+
+// normalize lowercases a name and strips everything but letters and digits.
+func normalize(s string) string {
+	return reNonAlnum.ReplaceAllString(strings.ToLower(s), "")
+}
+
+// visibility describes whether a pass is sunlit and, if the observer is in
+// darkness (the sun is below civil twilight), visible to the naked eye.
+func visibility(p astro.Pass, lat, lon float64) string {
+	if !p.Sunlit {
+		return "in earth's shadow"
+	}
+
+	var (
+		jd         = astro.JulianDay(p.Max)
+		ra, dec, _ = astro.SunPosition(jd)
+		sunAlt, _  = astro.Horizontal(ra, dec, lat, lon, jd)
+	)
+	if sunAlt < 90-astro.ZenithCivil {
+		return "sunlit, visible"
+	}
+
+	return "sunlit, sky too bright"
+}
+
+// riseSet formats a rise or set time.
+func riseSet(name string, t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return "no " + name + " today"
+	}
+
+	return name + " = " + t.In(loc).Format("15:04")
+}
+
+// compass returns the 8 point compass direction of an azimuth, eg: NE.
+func compass(az float64) string {
+	return []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}[int(math.Mod(az+22.5, 360)/45)]
 }
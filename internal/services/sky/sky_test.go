@@ -0,0 +1,143 @@
+package sky
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/geo/geotest"
+	"github.com/miekg/dns"
+)
+
+// Element sets with their epochs set to now so that the positions and
+// passes are predicted close to the epoch.
+var tles = []struct{ name, line1, line2 string }{
+	{"ISS (ZARYA)",
+		"1 25544U 98067A   24061.50000000  .00016717  00000-0  30375-3 0  9990",
+		"2 25544  51.6416 247.4627 0006703 130.5360 325.0288 15.72125391563537"},
+
+	// An equatorial orbit that's never above the horizon in Berlin.
+	{"EQUATOR SAT",
+		"1 99990U 24001A   24061.50000000  .00000000  00000-0  00000-0 0  9990",
+		"2 99990   0.0000 247.4627 0006703 130.5360 325.0288 15.72125391563537"},
+
+	{"TEST SAT",
+		"1 99991U 24001B   24061.50000000  .00000000  00000-0  00000-0 0  9990",
+		"2 99991  51.6416 247.4627 0006703 130.5360 325.0288 15.72125391563537"},
+	{"TEST SAT",
+		"1 99992U 24001C   24061.50000000  .00000000  00000-0  00000-0 0  9990",
+		"2 99992  51.6416 127.4627 0006703 130.5360 325.0288 15.72125391563537"},
+	{"TEST SAT",
+		"1 99993U 24001D   24061.50000000  .00000000  00000-0  00000-0 0  9990",
+		"2 99993  51.6416   7.4627 0006703 130.5360 325.0288 15.72125391563537"},
+	{"TEST SAT",
+		"1 99994U 24001E   24061.50000000  .00000000  00000-0  00000-0 0  9990",
+		"2 99994  97.4000  57.4627 0006703 130.5360 325.0288 15.72125391563537"},
+}
+
+func newTestSky(t *testing.T) *Sky {
+	t.Helper()
+
+	var (
+		now   = time.Now().UTC()
+		day   = float64(now.YearDay()) + float64(now.Sub(now.Truncate(24*time.Hour)))/float64(24*time.Hour)
+		epoch = fmt.Sprintf("%02d%012.8f", now.Year()%100, day)
+		b     strings.Builder
+	)
+	for _, e := range tles {
+		fmt.Fprintf(&b, "%s\n%s%s%s\n%s\n", e.name, e.line1[:18], epoch, e.line1[32:], e.line2)
+	}
+
+	path := filepath.Join(t.TempDir(), "tle.txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(Opt{TLEFile: path}, geotest.New(t, geotest.Berlin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestQuery(t *testing.T) {
+	s := newTestSky(t)
+
+	// Satellites by catalog number, alias, and name.
+	for _, q := range []string{"25544", "iss", "zarya", "isszarya"} {
+		out, err := s.Query(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+		if len(out) != 1 || !strings.HasPrefix(out[0], q+` 10 TXT "ISS (ZARYA)" "id=25544" "lat=`) || !strings.Contains(out[0], "maps.google.com") {
+			t.Errorf("%s: unexpected answer: %v", q, out)
+		}
+	}
+
+	// Passes over a city.
+	out, err := s.Query("iss.berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) < 2 || !strings.HasPrefix(out[0], `iss.berlin 300 TXT "ISS (ZARYA) over Berlin (Europe/Berlin, `) || !strings.Contains(out[0], `"rise = `) {
+		t.Errorf("unexpected passes: %v", out)
+	}
+
+	out, err = s.Query("equatorsat.berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || !strings.HasSuffix(out[0], `"no passes above 10 deg in the next 3 days"`) {
+		t.Errorf("unexpected passes: %v", out)
+	}
+
+	for q, want := range map[string]string{
+		"nosat":        "unknown satellite.",
+		"12345":        "unknown satellite.",
+		"nosat.berlin": "unknown satellite.",
+		"iss.berln":    "unknown city",
+	} {
+		if _, err := s.Query(q); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: want error %q got %v", q, want, err)
+		}
+	}
+}
+
+func TestResponseSize(t *testing.T) {
+	s := newTestSky(t)
+
+	// Answers should fit in a 512 byte UDP message without compression.
+	for _, q := range []string{"iss", "testsat", "iss.berlin", "testsat.berlin", "mars", "mars.berlin"} {
+		out, err := s.Query(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+
+		m := &dns.Msg{}
+		m.SetQuestion(dns.Fqdn(q+".sky"), dns.TypeTXT)
+		for _, r := range out {
+			rr, err := dns.NewRR(r)
+			if err != nil {
+				t.Fatalf("%s: invalid RR: %v", r, err)
+			}
+			m.Answer = append(m.Answer, rr)
+		}
+		if n := m.Len(); n > 512 {
+			t.Errorf("%s: response is %d bytes", q, n)
+		}
+	}
+
+	// Results that are cut off are counted in the last record.
+	if out, _ := s.Query("testsat"); !strings.HasSuffix(out[len(out)-1], ` more satellites, dig by NORAD ID"`) {
+		t.Errorf("unexpected satellites: %v", out)
+	}
+	if out, _ := s.Query("iss.berlin"); !strings.HasSuffix(out[len(out)-1], ` more passes not shown"`) {
+		t.Errorf("unexpected passes: %v", out)
+	}
+}
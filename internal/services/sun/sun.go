@@ -0,0 +1,145 @@
+// Package sun returns sunrise, sunset, twilight, and day length for cities
+// computed locally with the NOAA solar equations.
+package sun
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/astro"
+	"github.com/knadh/dns.toys/internal/geo"
+)
+
+const (
+	// TTL is set to 1 hour (60*60=3,600) as answers for today change at midnight.
+	TTL = 3600
+
+	// DateTTL is set to 1 day (60*60*24 = 86,400) for answers for a given date.
+	DateTTL = 86400
+
+	dateFormat = "2006-01-02"
+
+	// maxResults is the max number of cities returned for an ambiguous name.
+	maxResults = 3
+)
+
+var reDate = regexp.MustCompile(`^(\d{4}\-\d{2}\-\d{2})\.(.+)$`)
+
+// Sun is the sunrise/sunset controller.
+type Sun struct {
+	geo *geo.Geo
+}
+
+// New returns a new instance of Sun.
+func New(g *geo.Geo) *Sun {
+	return &Sun{geo: g}
+}
+
+// Query returns the sun's events for a city today or on a given date,
+// eg: berlin, 2025-06-21.berlin.
+func (s *Sun) Query(q string) ([]string, error) {
+	var (
+		city = q
+		date = ""
+		ttl  = TTL
+	)
+	if m := reDate.FindStringSubmatch(q); m != nil {
+		date, city = m[1], m[2]
+		ttl = DateTTL
+	}
+
+	locs := s.geo.Query(city)
+	if locs == nil {
+		return nil, s.geo.NotFound(city)
+	}
+
+	out := make([]string, 0, len(locs))
+	for n, l := range locs {
+		if n == maxResults {
+			break
+		}
+
+		day := time.Now().In(l.Loc)
+		if date != "" {
+			d, err := time.ParseInLocation(dateFormat, date, l.Loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date. Use %s.", dateFormat)
+			}
+			day = d
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, l.Loc)
+
+		out = append(out, fmt.Sprintf(`%s %d TXT "%s (%s, %s)" "%s"`,
+			q, ttl, l.Name, l.Timezone, l.Place(), strings.Join(events(day, l.Lat, l.Lon), `" "`)))
+	}
+
+	return out, nil
+}
+
+// Dump is not implemented in this package.
+func (s *Sun) Dump() ([]byte, error) {
+	return nil, nil
+}
+
+// events returns the formatted sun events on a day at lat, lon.
+func events(day time.Time, lat, lon float64) []string {
+	var (
+		loc  = day.Location()
+		noon = astro.SolarNoon(day, lon)
+
+		rise, riseAbove, riseOK = astro.SunEvent(day, lat, lon, astro.ZenithSunrise, true)
+		set, _, setOK           = astro.SunEvent(day, lat, lon, astro.ZenithSunrise, false)
+	)
+
+	out := []string{day.Format("Mon, 02 Jan 2006 MST")}
+	switch {
+	case riseOK && setOK:
+		out = append(out,
+			"sunrise = "+rise.In(loc).Format("15:04"),
+			"sunset = "+set.In(loc).Format("15:04"),
+			"day length = "+formatDuration(set.Sub(rise)))
+	case riseAbove:
+		out = append(out, "polar day, the sun doesn't set", "day length = "+formatDuration(24*time.Hour))
+	default:
+		out = append(out, "polar night, the sun doesn't rise", "day length = "+formatDuration(0))
+	}
+	out = append(out, "solar noon = "+noon.In(loc).Format("15:04"))
+
+	// No twilight when the sun doesn't set.
+	if riseAbove {
+		return out
+	}
+
+	// Twilights from dawn to dusk.
+	for _, t := range []struct {
+		name   string
+		zenith float64
+	}{
+		{"civil", astro.ZenithCivil},
+		{"nautical", astro.ZenithNautical},
+		{"astronomical", astro.ZenithAstronomical},
+	} {
+		var (
+			dawn, above, ok = astro.SunEvent(day, lat, lon, t.zenith, true)
+			dusk, _, _      = astro.SunEvent(day, lat, lon, t.zenith, false)
+		)
+		switch {
+		case ok:
+			out = append(out, fmt.Sprintf("%s dawn = %s, dusk = %s", t.name, dawn.In(loc).Format("15:04"), dusk.In(loc).Format("15:04")))
+		case above:
+			out = append(out, fmt.Sprintf("%s twilight all night", t.name))
+		default:
+			out = append(out, fmt.Sprintf("no %s twilight", t.name))
+		}
+	}
+
+	return out
+}
+
+// formatDuration formats a duration as 16h50m.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
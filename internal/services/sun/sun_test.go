@@ -0,0 +1,63 @@
+package sun
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/geo/geotest"
+)
+
+func TestQuery(t *testing.T) {
+	s := New(geotest.New(t, geotest.Berlin, geotest.Tromso))
+
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"2024-06-21.berlin", `2024-06-21.berlin 86400 TXT "Berlin (Europe/Berlin, DE)" "Fri, 21 Jun 2024 CEST" "sunrise = 04:43" "sunset = 21:33" ` +
+			`"day length = 16h50m" "solar noon = 13:08" "civil dawn = 03:52, dusk = 22:23" "nautical dawn = 02:29, dusk = 23:47" "astronomical twilight all night"`},
+		{"2024-12-21.berlin/de", `2024-12-21.berlin/de 86400 TXT "Berlin (Europe/Berlin, DE)" "Sat, 21 Dec 2024 CET" "sunrise = 08:15" "sunset = 15:54" ` +
+			`"day length = 7h39m" "solar noon = 12:04" "civil dawn = 07:33, dusk = 16:35" "nautical dawn = 06:49, dusk = 17:20" "astronomical dawn = 06:07, dusk = 18:02"`},
+		{"2024-06-21.tromso", `2024-06-21.tromso 86400 TXT "Tromso (Europe/Oslo, NO)" "Fri, 21 Jun 2024 CEST" "polar day, the sun doesn't set" "day length = 24h00m" "solar noon = 12:46"`},
+		{"2024-12-21.tromso", `2024-12-21.tromso 86400 TXT "Tromso (Europe/Oslo, NO)" "Sat, 21 Dec 2024 CET" "polar night, the sun doesn't rise" "day length = 0h00m" ` +
+			`"solar noon = 11:42" "civil dawn = 09:31, dusk = 13:53" "nautical dawn = 07:46, dusk = 15:37" "astronomical dawn = 06:28, dusk = 16:56"`},
+	}
+	for _, tc := range tests {
+		out, err := s.Query(tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.q, err)
+			continue
+		}
+		if len(out) != 1 || out[0] != tc.want {
+			t.Errorf("%s: want %s got %s", tc.q, tc.want, out)
+		}
+	}
+
+	// Today's answers are cached for less time.
+	if out, err := s.Query("berlin"); err != nil || !strings.HasPrefix(out[0], "berlin 3600 TXT ") {
+		t.Errorf("unexpected answer for today: %v %v", out, err)
+	}
+
+	for q, want := range map[string]string{
+		"2024-13-45.berlin": "invalid date",
+		"berln":             "unknown city. Did you mean berlin?",
+		"2024-06-21.xyz":    "unknown city",
+	} {
+		if _, err := s.Query(q); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: want error %q got %v", q, want, err)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		0:                             "0h00m",
+		16*time.Hour + 50*time.Minute: "16h50m",
+		7*time.Hour + 39*time.Minute + 31*time.Second: "7h40m",
+	} {
+		if got := formatDuration(d); got != want {
+			t.Errorf("%v: want %s got %s", d, want, got)
+		}
+	}
+}
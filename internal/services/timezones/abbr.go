@@ -0,0 +1,119 @@
+package timezones
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// abbr is a timezone abbreviation. Abbreviations are fixed offsets, eg: PST
+// is always UTC-08:00, and Zone is a representative zone that observes it.
+type abbr struct {
+	Name   string
+	Offset int
+	Zone   string
+}
+
+// abbrs maps common timezone abbreviations to what they stand for.
+// Abbreviations aren't unique, eg: IST is used in India, Israel, and Ireland,
+// in which case all of them are listed, the most commonly used first.
+var abbrs = map[string][]abbr{
+	"UTC":  {{"Coordinated Universal Time", 0, "Etc/UTC"}},
+	"GMT":  {{"Greenwich Mean Time", 0, "Europe/London"}},
+	"BST":  {{"British Summer Time", hm(1, 0), "Europe/London"}, {"Bangladesh Standard Time", hm(6, 0), "Asia/Dhaka"}},
+	"IST":  {{"India Standard Time", hm(5, 30), "Asia/Kolkata"}, {"Israel Standard Time", hm(2, 0), "Asia/Jerusalem"}, {"Irish Standard Time", hm(1, 0), "Europe/Dublin"}},
+	"IDT":  {{"Israel Daylight Time", hm(3, 0), "Asia/Jerusalem"}},
+	"WET":  {{"Western European Time", 0, "Europe/Lisbon"}},
+	"WEST": {{"Western European Summer Time", hm(1, 0), "Europe/Lisbon"}},
+	"CET":  {{"Central European Time", hm(1, 0), "Europe/Paris"}},
+	"CEST": {{"Central European Summer Time", hm(2, 0), "Europe/Paris"}},
+	"EET":  {{"Eastern European Time", hm(2, 0), "Europe/Athens"}},
+	"EEST": {{"Eastern European Summer Time", hm(3, 0), "Europe/Athens"}},
+	"MSK":  {{"Moscow Standard Time", hm(3, 0), "Europe/Moscow"}},
+	"GST":  {{"Gulf Standard Time", hm(4, 0), "Asia/Dubai"}},
+	"PKT":  {{"Pakistan Standard Time", hm(5, 0), "Asia/Karachi"}},
+	"NPT":  {{"Nepal Time", hm(5, 45), "Asia/Kathmandu"}},
+	"ICT":  {{"Indochina Time", hm(7, 0), "Asia/Bangkok"}},
+	"WIB":  {{"Western Indonesia Time", hm(7, 0), "Asia/Jakarta"}},
+	"HKT":  {{"Hong Kong Time", hm(8, 0), "Asia/Hong_Kong"}},
+	"SGT":  {{"Singapore Time", hm(8, 0), "Asia/Singapore"}},
+	"AWST": {{"Australian Western Standard Time", hm(8, 0), "Australia/Perth"}},
+	"JST":  {{"Japan Standard Time", hm(9, 0), "Asia/Tokyo"}},
+	"KST":  {{"Korea Standard Time", hm(9, 0), "Asia/Seoul"}},
+	"ACST": {{"Australian Central Standard Time", hm(9, 30), "Australia/Adelaide"}},
+	"ACDT": {{"Australian Central Daylight Time", hm(10, 30), "Australia/Adelaide"}},
+	"AEST": {{"Australian Eastern Standard Time", hm(10, 0), "Australia/Sydney"}},
+	"AEDT": {{"Australian Eastern Daylight Time", hm(11, 0), "Australia/Sydney"}},
+	"NZST": {{"New Zealand Standard Time", hm(12, 0), "Pacific/Auckland"}},
+	"NZDT": {{"New Zealand Daylight Time", hm(13, 0), "Pacific/Auckland"}},
+	"WAT":  {{"West Africa Time", hm(1, 0), "Africa/Lagos"}},
+	"CAT":  {{"Central Africa Time", hm(2, 0), "Africa/Maputo"}},
+	"SAST": {{"South Africa Standard Time", hm(2, 0), "Africa/Johannesburg"}},
+	"EAT":  {{"East Africa Time", hm(3, 0), "Africa/Nairobi"}},
+	"BRT":  {{"Brasilia Time", hm(-3, 0), "America/Sao_Paulo"}},
+	"ART":  {{"Argentina Time", hm(-3, 0), "America/Argentina/Buenos_Aires"}},
+	"NST":  {{"Newfoundland Standard Time", hm(-3, -30), "America/St_Johns"}},
+	"NDT":  {{"Newfoundland Daylight Time", hm(-2, -30), "America/St_Johns"}},
+	"AST":  {{"Atlantic Standard Time", hm(-4, 0), "America/Halifax"}, {"Arabia Standard Time", hm(3, 0), "Asia/Riyadh"}},
+	"ADT":  {{"Atlantic Daylight Time", hm(-3, 0), "America/Halifax"}},
+	"EST":  {{"Eastern Standard Time", hm(-5, 0), "America/New_York"}},
+	"EDT":  {{"Eastern Daylight Time", hm(-4, 0), "America/New_York"}},
+	"CST":  {{"Central Standard Time", hm(-6, 0), "America/Chicago"}, {"China Standard Time", hm(8, 0), "Asia/Shanghai"}, {"Cuba Standard Time", hm(-5, 0), "America/Havana"}},
+	"CDT":  {{"Central Daylight Time", hm(-5, 0), "America/Chicago"}},
+	"MST":  {{"Mountain Standard Time", hm(-7, 0), "America/Denver"}},
+	"MDT":  {{"Mountain Daylight Time", hm(-6, 0), "America/Denver"}},
+	"PST":  {{"Pacific Standard Time", hm(-8, 0), "America/Los_Angeles"}, {"Philippine Standard Time", hm(8, 0), "Asia/Manila"}},
+	"PDT":  {{"Pacific Daylight Time", hm(-7, 0), "America/Los_Angeles"}},
+	"AKST": {{"Alaska Standard Time", hm(-9, 0), "America/Anchorage"}},
+	"AKDT": {{"Alaska Daylight Time", hm(-8, 0), "America/Anchorage"}},
+	"HST":  {{"Hawaii-Aleutian Standard Time", hm(-10, 0), "Pacific/Honolulu"}},
+}
+
+// hm returns the offset in seconds for hours and minutes.
+func hm(h, m int) int {
+	return h*3600 + m*60
+}
+
+// queryAbbr returns the current time for a timezone abbreviation, eg: IST.
+// Ambiguous abbreviations return one answer for each meaning.
+func (t *Timezones) queryAbbr(q, name string) ([]string, bool) {
+	list, ok := abbrs[strings.ToUpper(name)]
+	if !ok {
+		return nil, false
+	}
+
+	var (
+		now = time.Now()
+		out = make([]string, 0, len(list))
+	)
+	for _, a := range list {
+		var (
+			code = strings.ToUpper(name)
+			tm   = now.In(time.FixedZone(code, a.Offset))
+			r    = fmt.Sprintf("%s 1 TXT \"%s (%s, %s)\" \"%s\"", q, code, a.Name, formatOffset(a.Offset), tm.Format(time.RFC1123Z))
+		)
+
+		// Warn when the abbreviation isn't in use right now in its zone,
+		// eg: PST during summer when Los Angeles is on PDT.
+		if loc, err := time.LoadLocation(a.Zone); err == nil {
+			if z, off := now.In(loc).Zone(); off != a.Offset {
+				r += fmt.Sprintf(" \"%s is currently on %s (%s)\"", a.Zone, z, formatOffset(off))
+			}
+		}
+
+		out = append(out, r)
+	}
+
+	return out, true
+}
+
+// formatOffset formats an offset in seconds as UTC+05:30.
+func formatOffset(off int) string {
+	sign := "+"
+	if off < 0 {
+		sign = "-"
+		off = -off
+	}
+
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, off/3600, (off%3600)/60)
+}
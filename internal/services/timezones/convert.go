@@ -0,0 +1,356 @@
+package timezones
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxRelative is the max offset from now in relative times.
+	maxRelative = 366 * 24 * time.Hour
+
+	// maxAmbiguous is the max number of matches listed for an ambiguous
+	// place.
+	maxAmbiguous = 3
+)
+
+var (
+	// A date and time, a time (today), or a time relative to now, followed
+	// by one or more endpoints, eg: 2023-05-28T14:00-mumbai-paris,
+	// 14:00-asia/kolkata-utc+2, now+90m-tokyo.
+	reConvert = regexp.MustCompile(`(?i)^(\d{4}\-\d{2}\-\d{2}T\d{2}:\d{2}|\d{1,2}:\d{2}|now(?:[+\-](?:\d+[dhm])+)?)((?:\-[a-z0-9/:+]+)+)$`)
+
+	reOffset   = regexp.MustCompile(`(?i)^(?:utc|gmt)(?:([+\-])(\d{1,2})(?::?(\d{2}))?)?$`)
+	reRelative = regexp.MustCompile(`(\d+)([dhm])`)
+)
+
+// endpoint is a place to convert times to and from: a city, an IANA
+// timezone, or a UTC offset.
+type endpoint struct {
+	// Short name, eg: Berlin, Asia/Kolkata, UTC+05:30.
+	Name string
+
+	// Full description, eg: Berlin (Europe/Berlin, DE).
+	Desc string
+
+	// Country code of cities, eg: DE.
+	Country string
+
+	Loc *time.Location
+}
+
+// convertTime converts a time to one or more endpoints. With exactly two
+// endpoints, the time is converted from the first to the second. Otherwise,
+// the time is shown in all the endpoints.
+func (t *Timezones) convertTime(q, ts, eps string) ([]string, error) {
+	names, err := splitEndpoints(eps, func(z string) bool {
+		_, _, ok := t.geo.Zone(z)
+		return ok
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// now[+-offset] is an absolute instant that doesn't depend on the
+	// first endpoint.
+	var (
+		rel   = strings.HasPrefix(strings.ToLower(ts), "now")
+		relTm time.Time
+	)
+	if rel {
+		relTm, err = parseRelative(ts, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	} else if len(names) < 2 {
+		return nil, errors.New("need two or more places to convert between.")
+	}
+
+	if len(names) > maxCities {
+		return nil, fmt.Errorf("too many places (max %d).", maxCities)
+	}
+
+	// Resolve all the endpoints. From one place to another, ambiguous
+	// places have all their matches. Otherwise, they have to resolve to
+	// a single timezone.
+	all := make([][]endpoint, 0, len(names))
+	for _, n := range names {
+		if len(names) == 2 {
+			e, err := t.resolve(n)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, e)
+			continue
+		}
+
+		e, err := t.resolveOne(n)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, []endpoint{e})
+	}
+
+	parse := func(from endpoint) (time.Time, error) {
+		if rel {
+			return relTm, nil
+		}
+		return parseTime(ts, time.Now().In(from.Loc))
+	}
+
+	var out []string
+
+	// From one place to another. Ambiguous places (eg: london) produce
+	// answers for all the combinations.
+	if len(all) == 2 {
+		for _, from := range all[0] {
+			tm, err := parse(from)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, to := range all[1] {
+				r := fmt.Sprintf("%s 1 TXT \"%s %s\" = \"%s %s\" \"%s\"",
+					q,
+					from.Desc, tm.In(from.Loc).Format(time.RFC1123Z),
+					to.Desc, tm.In(to.Loc).Format(time.RFC1123Z),
+					dayShift(tm.In(from.Loc), tm.In(to.Loc)))
+
+				out = append(out, r)
+			}
+		}
+
+		return out, nil
+	}
+
+	// The time in all the places. The date shift is relative to the first place, or for relative times,
+	// to today in each place.
+	tm, err := parse(all[0][0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range all {
+		ref := tm.In(all[0][0].Loc)
+		if rel {
+			ref = time.Now().In(e[0].Loc)
+		}
+
+		r := fmt.Sprintf("%s 1 TXT \"%s\" \"%s\" \"%s\"",
+			q, e[0].Desc, tm.In(e[0].Loc).Format(time.RFC1123Z), dayShift(ref, tm.In(e[0].Loc)))
+		out = append(out, r)
+	}
+
+	return out, nil
+}
+
+// resolve resolves a UTC offset (utc+5:30), an IANA timezone (asia/kolkata),
+// or a city to one or more endpoints.
+func (t *Timezones) resolve(name string) ([]endpoint, error) {
+	// UTC offset.
+	if m := reOffset.FindStringSubmatch(name); m != nil {
+		var (
+			h, _    = strconv.Atoi(m[2])
+			mins, _ = strconv.Atoi(m[3])
+			off     = hm(h, mins)
+		)
+		if m[1] == "-" {
+			off = -off
+		}
+		if h > 14 || mins > 59 {
+			return nil, fmt.Errorf("invalid UTC offset: %s", name)
+		}
+
+		z := formatOffset(off)
+		return []endpoint{{Name: z, Desc: z, Loc: time.FixedZone(z, off)}}, nil
+	}
+
+	// IANA timezone.
+	if strings.Contains(name, "/") {
+		if z, loc, ok := t.geo.Zone(name); ok {
+			return []endpoint{{Name: z, Desc: z, Loc: loc}}, nil
+		}
+	}
+
+	// City.
+	locs := t.geo.Query(name)
+	if len(locs) == 0 {
+		return nil, fmt.Errorf("unknown city or zone: %s", name)
+	}
+
+	out := make([]endpoint, 0, len(locs))
+	for _, l := range locs {
+		out = append(out, endpoint{
+			Name:    l.Name,
+			Desc:    fmt.Sprintf("%s (%s, %s)", l.Name, l.Timezone, l.Place()),
+			Country: l.Country,
+			Loc:     l.Loc,
+		})
+	}
+
+	return out, nil
+}
+
+// resolveOne resolves a name to its first (most populous) match. Names that
+// match places in different timezones are ambiguous unless they're narrowed
+// down by a country and region, eg: london/gb, or portland/us/oregon.
+func (t *Timezones) resolveOne(name string) (endpoint, error) {
+	e, err := t.resolve(name)
+	if err != nil {
+		return endpoint{}, err
+	}
+
+	var (
+		seen  = map[string]bool{e[0].Loc.String(): true}
+		descs = []string{e[0].Desc}
+	)
+	for _, o := range e[1:] {
+		if z := o.Loc.String(); !seen[z] && len(descs) < maxAmbiguous {
+			seen[z] = true
+			descs = append(descs, o.Desc)
+		}
+	}
+	if len(descs) > 1 {
+		return endpoint{}, fmt.Errorf("%s is ambiguous: %s. Add a country code, eg: %s/%s.",
+			name, strings.Join(descs, ", "), name, strings.ToLower(e[0].Country))
+	}
+
+	return e[0], nil
+}
+
+// resolveAll resolves each name to a single endpoint.
+func (t *Timezones) resolveAll(names []string) ([]endpoint, error) {
+	if len(names) > maxCities {
+		return nil, fmt.Errorf("too many places (max %d).", maxCities)
+	}
+
+	out := make([]endpoint, 0, len(names))
+	for _, n := range names {
+		e, err := t.resolveOne(n)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+
+	return out, nil
+}
+
+// splitEndpoints splits -$a-$b-$c into endpoints. As - is also the sign of
+// negative UTC offsets, utc-5 is kept together, and so are the parts of
+// IANA zones with - in their names, eg: America/Port-au-Prince, that
+// isZone reports as valid.
+func splitEndpoints(s string, isZone func(string) bool) ([]string, error) {
+	var (
+		out   []string
+		parts = strings.Split(strings.TrimPrefix(s, "-"), "-")
+	)
+	for i := 0; i < len(parts); i++ {
+		p := parts[i]
+		if p == "" {
+			return nil, errors.New("invalid format.")
+		}
+
+		// The longest zone name with the parts that follow.
+		if strings.Contains(p, "/") {
+			for j := len(parts); j > i+1; j-- {
+				if z := strings.Join(parts[i:j], "-"); isZone(z) {
+					p, i = z, j-1
+					break
+				}
+			}
+		}
+
+		// A number following a bare utc/gmt is a negative offset.
+		if n := len(out); n > 0 && p[0] >= '0' && p[0] <= '9' {
+			if l := strings.ToLower(out[n-1]); l == "utc" || l == "gmt" {
+				out[n-1] += "-" + p
+				continue
+			}
+		}
+
+		out = append(out, p)
+	}
+
+	return out, nil
+}
+
+// parseTime parses a date and time (2006-01-02T15:04) or just a time (15:04)
+// for today in the location of now.
+func parseTime(ts string, now time.Time) (time.Time, error) {
+	if len(ts) > 5 {
+		tm, err := time.ParseInLocation(inFormat, strings.ToUpper(ts), now.Location())
+		if err != nil {
+			return time.Time{}, errors.New("invalid time format")
+		}
+		return tm, nil
+	}
+
+	tm, err := time.Parse("15:04", ts)
+	if err != nil {
+		return time.Time{}, errors.New("invalid time format")
+	}
+
+	return time.Date(now.Year(), now.Month(), now.Day(), tm.Hour(), tm.Minute(), 0, 0, now.Location()), nil
+}
+
+// parseRelative parses now, now+90m, now-2h, now+1d12h relative to now.
+func parseRelative(ts string, now time.Time) (time.Time, error) {
+	ts = strings.ToLower(ts)
+	if ts == "now" {
+		return now, nil
+	}
+
+	var d time.Duration
+	for _, m := range reRelative.FindAllStringSubmatch(ts[4:], -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, errors.New("invalid relative time.")
+		}
+
+		unit := time.Minute
+		switch m[2] {
+		case "d":
+			unit = 24 * time.Hour
+		case "h":
+			unit = time.Hour
+		}
+
+		// Check the bounds before multiplying so that the duration
+		// doesn't overflow.
+		if n > int(maxRelative/unit) {
+			return time.Time{}, errors.New("relative time is too far.")
+		}
+		if d += time.Duration(n) * unit; d > maxRelative {
+			return time.Time{}, errors.New("relative time is too far.")
+		}
+	}
+	if ts[3] == '-' {
+		d = -d
+	}
+
+	return now.Add(d), nil
+}
+
+// dayShift returns the shift in calendar days from ref's date to tm's date,
+// eg: same day, +1 day.
+func dayShift(ref, tm time.Time) string {
+	var (
+		a = time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, time.UTC)
+		b = time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, time.UTC)
+		d = int(b.Sub(a).Hours() / 24)
+	)
+
+	switch d {
+	case 0:
+		return "same day"
+	case 1, -1:
+		return fmt.Sprintf("%+d day", d)
+	}
+
+	return fmt.Sprintf("%+d days", d)
+}
@@ -0,0 +1,74 @@
+package timezones
+
+import (
+	"fmt"
+	"time"
+)
+
+// transition is a change in a zone's UTC offset, eg: the start or end of DST.
+type transition struct {
+	At time.Time
+
+	FromName   string
+	FromOffset int
+	ToName     string
+	ToOffset   int
+}
+
+// maxScan is how far to look ahead and behind for transitions.
+const maxScan = 366 * 24 * time.Hour
+
+// findTransition returns the first offset transition in loc after (or
+// before, if backward is true) the given time within maxScan. Go doesn't
+// expose the tz database transitions, so the offsets are scanned daily
+// and the exact instant is narrowed down with a binary search.
+func findTransition(loc *time.Location, from time.Time, backward bool) (transition, bool) {
+	step := 24 * time.Hour
+	if backward {
+		step = -step
+	}
+
+	var (
+		_, off = from.In(loc).Zone()
+		prev   = from
+	)
+	for d := step; d.Abs() <= maxScan; d += step {
+		cur := from.Add(d)
+		if _, o := cur.In(loc).Zone(); o == off {
+			prev = cur
+			continue
+		}
+
+		// The transition is between prev and cur. Binary search for the
+		// first second with the new offset.
+		lo, hi := prev.Unix(), cur.Unix()
+		if backward {
+			lo, hi = hi, lo
+		}
+		_, loOff := time.Unix(lo, 0).In(loc).Zone()
+		for hi-lo > 1 {
+			mid := lo + (hi-lo)/2
+			if _, o := time.Unix(mid, 0).In(loc).Zone(); o == loOff {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+
+		var (
+			at             = time.Unix(hi, 0).In(loc)
+			fromName, fOff = time.Unix(lo, 0).In(loc).Zone()
+			toName, tOff   = at.Zone()
+		)
+		return transition{At: at, FromName: fromName, FromOffset: fOff, ToName: toName, ToOffset: tOff}, true
+	}
+
+	return transition{}, false
+}
+
+// formatTransition formats a transition, eg:
+// "Sun, 25 Oct 2026 01:00:00 UTC: CEST (UTC+02:00) -> CET (UTC+01:00)".
+func formatTransition(t transition) string {
+	return fmt.Sprintf("%s: %s (%s) -> %s (%s)", t.At.UTC().Format(time.RFC1123),
+		t.FromName, formatOffset(t.FromOffset), t.ToName, formatOffset(t.ToOffset))
+}
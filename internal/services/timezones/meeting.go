@@ -0,0 +1,113 @@
+package timezones
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Local working hours (09:00 to 17:00, Monday to Friday) that are
+// considered for overlapping windows.
+const (
+	workStart = 9
+	workEnd   = 17
+
+	// Granularity of the overlap scan. All UTC offsets are multiples of it.
+	overlapStep = 15 * time.Minute
+
+	// How far ahead to look for overlapping windows.
+	overlapDays = 7
+)
+
+// overlap returns the next window (today's, if it's not over yet) where
+// the working hours of all the listed cities overlap, eg:
+// berlin,newyork,asia/tokyo.overlap. Cities that match places in
+// different timezones have to be narrowed down, eg: london/gb.
+func (t *Timezones) overlap(q string, cities []string) ([]string, error) {
+	if len(cities) < 2 {
+		return nil, errors.New("need two or more comma separated cities.")
+	}
+
+	locs, err := t.resolveAll(cities)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to, ok := findOverlap(locs, time.Now())
+	if !ok {
+		return []string{fmt.Sprintf(`%s 1 TXT "no overlapping working hours (%02d:00-%02d:00, Mon-Fri) in the next %d days"`,
+			q, workStart, workEnd, overlapDays)}, nil
+	}
+
+	r := fmt.Sprintf(`%s 1 TXT "overlap = %s"`, q, formatDuration(to.Sub(from)))
+	for _, l := range locs {
+		var (
+			f = from.In(l.Loc)
+			e = to.In(l.Loc)
+		)
+		r += fmt.Sprintf(` "%s: %s %s-%s %s"`, l.Name, f.Format("Mon 02 Jan"), f.Format("15:04"), e.Format("15:04"), zoneName(e))
+	}
+
+	return []string{r}, nil
+}
+
+// findOverlap returns the first window ending after now where it's working
+// hours in all the given locations.
+func findOverlap(locs []endpoint, now time.Time) (time.Time, time.Time, bool) {
+	isWork := func(tm time.Time) bool {
+		for _, l := range locs {
+			lt := tm.In(l.Loc)
+			if lt.Weekday() == time.Saturday || lt.Weekday() == time.Sunday ||
+				lt.Hour() < workStart || lt.Hour() >= workEnd {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Scan from the start of the current day in the first city.
+	var (
+		n     = now.In(locs[0].Loc)
+		start = time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, n.Location()).UTC()
+		end   = start.Add(overlapDays * 24 * time.Hour)
+
+		from time.Time
+		in   bool
+	)
+	for tm := start; tm.Before(end); tm = tm.Add(overlapStep) {
+		w := isWork(tm)
+		switch {
+		case w && !in:
+			from, in = tm, true
+		case !w && in:
+			if tm.After(now) {
+				return from, tm, true
+			}
+			in = false
+		}
+	}
+
+	return time.Time{}, time.Time{}, false
+}
+
+// formatDuration formats a duration as 2h30m, 2h, or 45m.
+func formatDuration(d time.Duration) string {
+	s := strings.TrimSuffix(d.Truncate(time.Minute).String(), "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+
+	return s
+}
+
+// zoneName returns the abbreviation of a time's zone, or its UTC offset
+// when the zone has no abbreviation (eg: +09).
+func zoneName(tm time.Time) string {
+	z, off := tm.Zone()
+	if z == "" || z[0] == '+' || z[0] == '-' {
+		return formatOffset(off)
+	}
+
+	return z
+}
@@ -2,7 +2,6 @@
 package timezones
 
 import (
-	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -15,8 +14,18 @@ const (
 	inFormat = "2006-01-02T15:04"
 )
 
+// Query modifiers, eg: berlin.offset.time.
+const (
+	modOffset  = "offset"
+	modDST     = "dst"
+	modOverlap = "overlap"
+)
+
+// maxCities is the max number of cities in a multi-city query.
+const maxCities = 8
+
 var (
-	reqQuery = regexp.MustCompile(`(?i)(\d{4}\-\d{2}\-\d{2}T\d{2}:\d{2})\-([a-z/]+)\-([a-z/]+)`)
+	reAbbr = regexp.MustCompile(`^[A-Z]{2,5}$`)
 )
 
 // Timezones controller returns times for various geographic locations.
@@ -35,66 +44,97 @@ func New(o Opt, g *geo.Geo) *Timezones {
 }
 
 // Query parses a given query string and returns the answer.
-// For the time package, the query is a location name.
+// For the time package, the query is a location name or a timezone
+// abbreviation, optionally followed by a modifier, eg: berlin.dst, IST.
 func (t *Timezones) Query(q string) ([]string, error) {
 	// Convert from -> to time.
-	if m := reqQuery.FindStringSubmatch(strings.TrimSpace(q)); len(m) == 4 {
-		return t.convert(q, m)
+	if m := reConvert.FindStringSubmatch(strings.TrimSpace(q)); len(m) == 3 {
+		return t.convertTime(q, m[1], m[2])
 	}
 
-	// Get time from a timezone.
-	locs := t.geo.Query(q)
-	if locs == nil {
-		return nil, errors.New("unknown city.")
-	}
-
-	out := make([]string, 0, len(locs))
-	for _, l := range locs {
-		r := fmt.Sprintf("%s 1 TXT \"%s (%s, %s)\" \"%s\"",
-			q, l.Name, l.Timezone, l.Country, time.Now().In(l.Loc).Format(time.RFC1123Z))
-
-		out = append(out, r)
+	name, mod := q, ""
+	if i := strings.LastIndex(q, "."); i > 0 {
+		switch q[i+1:] {
+		case modOffset, modDST, modOverlap:
+			name, mod = q[:i], q[i+1:]
+		}
 	}
 
-	return out, nil
-}
-
-// Dump produces a gob dump of the cached data.
-func (t *Timezones) Dump() ([]byte, error) {
-	return nil, nil
-}
-
-func (t *Timezones) convert(q string, m []string) ([]string, error) {
-	// Timestamp, zone to convert from, zone to convert to.
-	ts, fromGeo, toGeo := m[1], m[2], m[3]
-
-	// Get one or more from->to time.Location zones.
-	fromLocs := t.geo.Query(fromGeo)
-	if len(fromLocs) == 0 {
-		return nil, errors.New("unknown `from` city.")
+	// Overlapping working hours across cities.
+	if mod == modOverlap {
+		return t.overlap(q, strings.Split(name, ","))
 	}
 
-	toLocs := t.geo.Query(toGeo)
-	if len(toLocs) == 0 {
-		return nil, errors.New("unknown `from` city.")
+	// Upper case abbreviations (IST) take precedence over cities.
+	if reAbbr.MatchString(name) {
+		if out, ok := t.queryAbbr(q, name); ok {
+			return out, nil
+		}
 	}
 
-	var out []string
-	for _, from := range fromLocs {
-		tm, err := time.ParseInLocation(inFormat, ts, from.Loc)
-		if err != nil {
-			return nil, errors.New("invalid time format")
+	// Get time from an IANA timezone (asia/kolkata) or a city.
+	var eps []endpoint
+	if z, loc, ok := t.geo.Zone(name); strings.Contains(name, "/") && ok {
+		eps = []endpoint{{Name: z, Desc: z, Loc: loc}}
+	} else {
+		locs := t.geo.Query(name)
+		if locs == nil {
+			if out, ok := t.queryAbbr(q, name); ok {
+				return out, nil
+			}
+			return nil, t.geo.NotFound(name)
 		}
 
-		for _, to := range toLocs {
-			r := fmt.Sprintf("%s 1 TXT \"%s (%s, %s) %s\" = \"%s (%s, %s) %s\"",
-				q,
-				from.Name, from.Timezone, from.Country, tm.In(from.Loc).Format(time.RFC1123Z),
-				to.Name, to.Timezone, to.Country, tm.In(to.Loc).Format(time.RFC1123Z))
+		for _, l := range locs {
+			eps = append(eps, endpoint{
+				Name:    l.Name,
+				Desc:    fmt.Sprintf("%s (%s, %s)", l.Name, l.Timezone, l.Place()),
+				Country: l.Country,
+				Loc:     l.Loc,
+			})
+		}
+	}
 
-			out = append(out, r)
+	var (
+		now = time.Now()
+		out = make([]string, 0, len(eps))
+	)
+	for _, l := range eps {
+		r := fmt.Sprintf("%s 1 TXT \"%s\"", q, l.Desc)
+
+		switch mod {
+		case modOffset:
+			z, off := now.In(l.Loc).Zone()
+			r += fmt.Sprintf(" \"%s\" \"%s\"", formatOffset(off), z)
+			if now.In(l.Loc).IsDST() {
+				r += " \"DST in effect\""
+			}
+
+		case modDST:
+			prev, okPrev := findTransition(l.Loc, now, true)
+			next, okNext := findTransition(l.Loc, now, false)
+			if !okPrev && !okNext {
+				r += " \"no DST transitions\""
+				break
+			}
+			if okPrev {
+				r += fmt.Sprintf(" \"previous = %s\"", formatTransition(prev))
+			}
+			if okNext {
+				r += fmt.Sprintf(" \"next = %s\"", formatTransition(next))
+			}
+
+		default:
+			r += fmt.Sprintf(" \"%s\"", now.In(l.Loc).Format(time.RFC1123Z))
 		}
+
+		out = append(out, r)
 	}
 
 	return out, nil
 }
+
+// Dump produces a gob dump of the cached data.
+func (t *Timezones) Dump() ([]byte, error) {
+	return nil, nil
+}
@@ -0,0 +1,214 @@
+package timezones
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knadh/dns.toys/internal/geo/geotest"
+)
+
+func TestFindTransition(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	next, ok := findTransition(berlin, from, false)
+	if !ok || formatTransition(next) != "Sun, 27 Oct 2024 01:00:00 UTC: CEST (UTC+02:00) -> CET (UTC+01:00)" {
+		t.Errorf("unexpected next transition: %s", formatTransition(next))
+	}
+
+	prev, ok := findTransition(berlin, from, true)
+	if !ok || formatTransition(prev) != "Sun, 31 Mar 2024 01:00:00 UTC: CET (UTC+01:00) -> CEST (UTC+02:00)" {
+		t.Errorf("unexpected previous transition: %s", formatTransition(prev))
+	}
+
+	if _, ok := findTransition(kolkata, from, false); ok {
+		t.Errorf("expected no transitions for Asia/Kolkata")
+	}
+}
+
+func TestAbbr(t *testing.T) {
+	tz := &Timezones{}
+
+	out, ok := tz.queryAbbr("IST", "IST")
+	if !ok || len(out) != 3 || !strings.Contains(out[0], "India Standard Time, UTC+05:30") {
+		t.Errorf("unexpected IST answer: %v", out)
+	}
+
+	out, _ = tz.queryAbbr("nst", "nst")
+	if !strings.Contains(out[0], "UTC-03:30") {
+		t.Errorf("unexpected NST answer: %v", out)
+	}
+
+	if _, ok := tz.queryAbbr("XYZ", "XYZ"); ok {
+		t.Errorf("expected unknown abbreviation")
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	loc := func(name string) endpoint {
+		l, _ := time.LoadLocation(name)
+		return endpoint{Name: name, Desc: name, Loc: l}
+	}
+
+	var (
+		berlin  = loc("Europe/Berlin")
+		newYork = loc("America/New_York")
+		tokyo   = loc("Asia/Tokyo")
+		kolkata = loc("Asia/Kolkata")
+	)
+
+	// Monday. New York is already on DST, Berlin isn't.
+	now := time.Date(2025, 3, 10, 6, 0, 0, 0, time.UTC)
+	from, to, ok := findOverlap([]endpoint{berlin, newYork}, now)
+	if !ok || !from.Equal(time.Date(2025, 3, 10, 13, 0, 0, 0, time.UTC)) || to.Sub(from) != 3*time.Hour {
+		t.Errorf("unexpected overlap: %v %v %v", from, to, ok)
+	}
+
+	// Half hour offsets.
+	from, to, ok = findOverlap([]endpoint{berlin, kolkata}, now)
+	if !ok || !from.Equal(time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)) || to.Sub(from) != 3*time.Hour+30*time.Minute {
+		t.Errorf("unexpected overlap: %v %v %v", from, to, ok)
+	}
+
+	// Today's window is over, so it's the next working day's.
+	now = time.Date(2025, 3, 14, 18, 0, 0, 0, time.UTC)
+	if from, _, ok = findOverlap([]endpoint{berlin, newYork}, now); !ok || from.Day() != 17 {
+		t.Errorf("unexpected next overlap: %v %v", from, ok)
+	}
+
+	if _, _, ok := findOverlap([]endpoint{newYork, tokyo}, now); ok {
+		t.Errorf("expected no overlap")
+	}
+
+	for d, want := range map[time.Duration]string{150 * time.Minute: "2h30m", 2 * time.Hour: "2h", 45 * time.Minute: "45m"} {
+		if s := formatDuration(d); s != want {
+			t.Errorf("unexpected duration: %s", s)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	for in, want := range map[string]string{
+		"-berlin-tokyo":          "berlin|tokyo",
+		"-utc-5-asia/kolkata":    "utc-5|asia/kolkata",
+		"-gmt-3:30-utc+2-berlin": "gmt-3:30|utc+2|berlin",
+
+		// Zones with - in their names.
+		"-america/port-au-prince-berlin": "america/port-au-prince|berlin",
+		"-berlin-america/port-au-prince": "berlin|america/port-au-prince",
+		"-asia/kolkata-utc-5":            "asia/kolkata|utc-5",
+	} {
+		got, err := splitEndpoints(in, func(z string) bool {
+			return z == "america/port-au-prince" || z == "asia/kolkata"
+		})
+		if err != nil || strings.Join(got, "|") != want {
+			t.Errorf("%s: want %s got %v %v", in, want, got, err)
+		}
+	}
+
+	tz := &Timezones{}
+	for in, want := range map[string]string{
+		"utc":       "UTC+00:00",
+		"utc+5:30":  "UTC+05:30",
+		"UTC+0530":  "UTC+05:30",
+		"gmt-3:30":  "UTC-03:30",
+		"utc-10":    "UTC-10:00",
+		"utc+13:45": "UTC+13:45",
+	} {
+		e, err := tz.resolve(in)
+		if err != nil || e[0].Name != want {
+			t.Errorf("%s: want %s got %v %v", in, want, e, err)
+			continue
+		}
+		if _, off := time.Now().In(e[0].Loc).Zone(); formatOffset(off) != want {
+			t.Errorf("%s: wrong offset %d", in, off)
+		}
+	}
+	if _, err := tz.resolve("utc+15"); err == nil {
+		t.Errorf("expected error for invalid offset")
+	}
+
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	now := time.Date(2025, 3, 10, 22, 30, 0, 0, tokyo)
+
+	if tm, _ := parseTime("09:15", now); !tm.Equal(time.Date(2025, 3, 10, 9, 15, 0, 0, tokyo)) {
+		t.Errorf("unexpected time: %v", tm)
+	}
+	if tm, _ := parseTime("2025-01-02t03:04", now); !tm.Equal(time.Date(2025, 1, 2, 3, 4, 0, 0, tokyo)) {
+		t.Errorf("unexpected date time: %v", tm)
+	}
+	if _, err := parseTime("25:00", now); err == nil {
+		t.Errorf("expected error for invalid time")
+	}
+
+	for in, want := range map[string]time.Duration{
+		"now":       0,
+		"now+90m":   90 * time.Minute,
+		"now-2h":    -2 * time.Hour,
+		"now+1d12h": 36 * time.Hour,
+	} {
+		tm, err := parseRelative(in, now)
+		if err != nil || tm.Sub(now) != want {
+			t.Errorf("%s: want %v got %v %v", in, want, tm.Sub(now), err)
+		}
+	}
+	for _, in := range []string{"now+400d", "now+200000d", "now-200000d", "now+9999999999h", "now+300d300d", "now+366d1m"} {
+		if _, err := parseRelative(in, now); err == nil {
+			t.Errorf("%s: expected error for far relative time", in)
+		}
+	}
+
+	// 22:30 in Tokyo is the next day in UTC+14 and the same day in Berlin.
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	for loc, want := range map[*time.Location]string{
+		time.FixedZone("", 14*3600):  "+1 day",
+		berlin:                       "same day",
+		time.FixedZone("", -12*3600): "same day",
+	} {
+		if s := dayShift(now, now.In(loc)); s != want {
+			t.Errorf("%v: want %s got %s", loc, want, s)
+		}
+	}
+	if s := dayShift(now, now.Add(-72*time.Hour)); s != "-3 days" {
+		t.Errorf("unexpected shift: %s", s)
+	}
+}
+
+func TestQueryPlaces(t *testing.T) {
+	// Berlin, New Hampshire is in a different timezone from Berlin, DE.
+	tz := New(Opt{}, geotest.New(t, geotest.Berlin, geotest.Bengaluru,
+		"5083330\tBerlin\tBerlin\t\t44.46867\t-71.18508\tP\tPPL\tUS\t\tNH\t\t\t\t9367\t\t311\tAmerica/New_York\t2022-01-01"))
+
+	// Bare IANA zones.
+	for _, q := range []string{"asia/kolkata", "Asia/Kolkata", "asia/kolkata.offset"} {
+		out, err := tz.Query(q)
+		if err != nil || len(out) != 1 || !strings.HasPrefix(out[0], q+` 1 TXT "Asia/Kolkata" "`) {
+			t.Errorf("%s: unexpected answer: %v %v", q, out, err)
+		}
+	}
+
+	// Ambiguous places have to be narrowed down in multi-place queries.
+	_, err := tz.Query("10:00-berlin-bengaluru-utc")
+	if err == nil || !strings.Contains(err.Error(), "berlin is ambiguous: Berlin (Europe/Berlin, DE), Berlin (America/New_York, US). Add a country code, eg: berlin/de.") {
+		t.Errorf("expected ambiguous place error, got %v", err)
+	}
+	if _, err := tz.Query("berlin,bengaluru.overlap"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("expected ambiguous place error, got %v", err)
+	}
+
+	out, err := tz.Query("10:00-berlin/us-bengaluru-utc")
+	if err != nil || len(out) != 3 || !strings.Contains(out[0], `"Berlin (America/New_York, US)"`) {
+		t.Errorf("unexpected answer: %v %v", out, err)
+	}
+	out, err = tz.Query("2025-01-02T10:00-berlin/de-asia/kolkata-utc")
+	if err != nil || len(out) != 3 || out[1] != `2025-01-02T10:00-berlin/de-asia/kolkata-utc 1 TXT "Asia/Kolkata" "Thu, 02 Jan 2025 14:30:00 +0530" "same day"` {
+		t.Errorf("unexpected answer: %v %v", out, err)
+	}
+
+	// From one place to another, ambiguous places have all their matches.
+	if out, err := tz.Query("10:00-berlin-bengaluru"); err != nil || len(out) != 2 {
+		t.Errorf("unexpected answer: %v %v", out, err)
+	}
+}
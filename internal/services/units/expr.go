@@ -0,0 +1,307 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Base dimensions that all units are expressed in.
+const (
+	dimLength = iota
+	dimMass
+	dimTime
+	dimTemp
+	dimCurrent
+	dimInfo
+	numDims
+)
+
+// Symbols of the SI (and information) base units in the order of the
+// dimensions above.
+var dimSymbols = [numDims]string{"m", "kg", "s", "K", "A", "bit"}
+
+// maxExpressions is the max number of interpretations of an expression with
+// ambiguous symbols (eg: oz, mass or volume) that are considered.
+const maxExpressions = 16
+
+// dims are the exponents of the base dimensions of a quantity, eg:
+// speed (m/s) is {1, 0, -1, 0, 0, 0}.
+type dims [numDims]int
+
+// String returns the dimensions in terms of the base units, eg: kg.m2/s2.
+func (d dims) String() string {
+	var num, den []string
+	for i, e := range d {
+		switch {
+		case e == 1:
+			num = append(num, dimSymbols[i])
+		case e > 1:
+			num = append(num, fmt.Sprintf("%s%d", dimSymbols[i], e))
+		case e == -1:
+			den = append(den, dimSymbols[i])
+		case e < -1:
+			den = append(den, fmt.Sprintf("%s%d", dimSymbols[i], -e))
+		}
+	}
+
+	out := strings.Join(num, ".")
+	if out == "" {
+		out = "1"
+	}
+	if len(den) > 0 {
+		out += "/" + strings.Join(den, "/")
+	}
+
+	return out
+}
+
+// quantity is a parsed unit expression: a factor to the SI base units and
+// its dimensions.
+type quantity struct {
+	// Symbol is the expression, eg: km, mi/h. Name is only set for single
+	// units, eg: Kilometer.
+	Symbol string
+	Name   string
+
+	Factor float64
+	Dims   dims
+
+	// Kind, Offset and System of single units.
+	Kind   string
+	Offset float64
+	System string
+}
+
+// newQuantity returns the quantity of a single unit.
+func newQuantity(un unit) quantity {
+	q := quantity{
+		Symbol: un.Symbol,
+		Name:   un.Name,
+		Factor: 1,
+		Kind:   un.Kind,
+		Offset: un.Offset,
+		System: un.System,
+	}
+
+	return q.mul(un, 1)
+}
+
+// errAbsZero is returned for temperatures below absolute zero.
+var errAbsZero = errors.New("temperature is below absolute zero.")
+
+// toBase converts a value in the quantity to the base units. Temperatures
+// (offset units and the absolute scales, K and R) below 0 K are an error.
+func (q quantity) toBase(v float64) (float64, error) {
+	var b float64
+	switch q.Kind {
+	case kindOffset:
+		b = (v + q.Offset) * q.Factor
+	case kindInverse:
+		return q.Factor / v, nil
+	default:
+		b = v * q.Factor
+	}
+
+	if b < 0 && (q.Kind == kindOffset || q.Dims == (dims{dimTemp: 1})) {
+		return 0, errAbsZero
+	}
+
+	return b, nil
+}
+
+// fromBase converts a value in the base units to the quantity.
+func (q quantity) fromBase(v float64) float64 {
+	switch q.Kind {
+	case kindOffset:
+		return v/q.Factor - q.Offset
+	case kindInverse:
+		return q.Factor / v
+	}
+
+	return v / q.Factor
+}
+
+// label returns the name and symbol of the quantity, eg: Kilometer (km).
+func (q quantity) label() string {
+	if q.Name == "" {
+		return q.Symbol
+	}
+
+	return fmt.Sprintf("%s (%s)", q.Name, q.Symbol)
+}
+
+// mul multiplies the quantity by a unit raised to an exponent.
+func (q quantity) mul(un unit, exp int) quantity {
+	for i := 0; i < exp; i++ {
+		q.Factor *= un.Factor
+	}
+	for i := 0; i > exp; i-- {
+		q.Factor /= un.Factor
+	}
+	for i := range q.Dims {
+		q.Dims[i] += un.dims[i] * exp
+	}
+
+	return q
+}
+
+// SI prefixes that can be applied to units that allow them.
+var prefixes = []struct {
+	Symbol string
+	Name   string
+	Factor float64
+}{
+	{"Q", "Quetta", 1e30},
+	{"R", "Ronna", 1e27},
+	{"Y", "Yotta", 1e24},
+	{"Z", "Zetta", 1e21},
+	{"E", "Exa", 1e18},
+	{"P", "Peta", 1e15},
+	{"T", "Tera", 1e12},
+	{"G", "Giga", 1e9},
+	{"M", "Mega", 1e6},
+	{"k", "Kilo", 1e3},
+	{"h", "Hecto", 1e2},
+	{"da", "Deca", 1e1},
+	{"d", "Deci", 1e-1},
+	{"c", "Centi", 1e-2},
+	{"m", "Milli", 1e-3},
+	{"u", "Micro", 1e-6},
+	{"n", "Nano", 1e-9},
+	{"p", "Pico", 1e-12},
+	{"f", "Femto", 1e-15},
+	{"a", "Atto", 1e-18},
+	{"z", "Zepto", 1e-21},
+	{"y", "Yocto", 1e-24},
+	{"r", "Ronto", 1e-27},
+	{"q", "Quecto", 1e-30},
+}
+
+// lookup returns the units for a symbol. Symbols that aren't in the list
+// are tried with SI prefixes (eg: MJ, kWh), then in lowercase, and then
+// case-insensitively (eg: kwh, mj) as DNS names are case-insensitive.
+func (u *Units) lookup(sym string) []unit {
+	if un, ok := u.symbols[sym]; ok {
+		return un
+	}
+	if out := u.prefixed(sym, false); len(out) > 0 {
+		return out
+	}
+
+	if l := strings.ToLower(sym); l != sym {
+		if un, ok := u.symbols[l]; ok {
+			return un
+		}
+		if out := u.prefixed(l, false); len(out) > 0 {
+			return out
+		}
+	}
+
+	// Symbols are preferred over prefixed units, eg: pa is Pa, not p+a.
+	if un, ok := u.folded[strings.ToLower(sym)]; ok {
+		return un
+	}
+
+	return u.prefixed(sym, true)
+}
+
+// prefixed returns the units for a symbol with an SI prefix, optionally
+// matching the prefix and the unit case-insensitively. Larger prefixes are
+// first, eg: mwh is MWh and then mWh.
+func (u *Units) prefixed(sym string, fold bool) []unit {
+	var out []unit
+	for _, p := range prefixes {
+		if len(sym) <= len(p.Symbol) {
+			continue
+		}
+
+		units := u.symbols[sym[len(p.Symbol):]]
+		if fold {
+			if !strings.EqualFold(sym[:len(p.Symbol)], p.Symbol) {
+				continue
+			}
+			units = u.folded[strings.ToLower(sym[len(p.Symbol):])]
+		} else if !strings.HasPrefix(sym, p.Symbol) {
+			continue
+		}
+
+		for _, un := range units {
+			if !un.Prefix {
+				continue
+			}
+
+			un.Symbol = p.Symbol + un.Symbol
+			un.Name = p.Name + strings.ToLower(un.Name)
+			un.Factor *= p.Factor
+			out = append(out, un)
+		}
+	}
+
+	return out
+}
+
+// parse parses a unit expression into its possible quantities. An
+// expression is a unit (km), or units with optional exponents multiplied
+// with . and divided with / (mi/h, m/s2, kW.h, kg.m2/s2).
+func (u *Units) parse(expr string) ([]quantity, error) {
+	// A single unit, including ones with / in their symbols (eg: km/l).
+	if units := u.lookup(expr); len(units) > 0 {
+		out := make([]quantity, 0, len(units))
+		for _, un := range units {
+			out = append(out, newQuantity(un))
+		}
+		return out, nil
+	}
+
+	out := []quantity{{Symbol: expr, Factor: 1}}
+	for n, part := range strings.Split(expr, "/") {
+		// The numerator multiplies and the denominators divide.
+		sign := 1
+		if n > 0 {
+			sign = -1
+		}
+
+		for _, f := range strings.Split(part, ".") {
+			if f == "" {
+				return nil, fmt.Errorf("invalid unit: %s", expr)
+			}
+
+			units, exp := u.lookup(f), 1
+			if len(units) == 0 {
+				// A trailing digit is an exponent, eg: s2.
+				sym := strings.TrimRight(f, "0123456789")
+				if sym == "" || len(f)-len(sym) != 1 {
+					return nil, fmt.Errorf("unknown unit: %v. 'dig unit' to see list of units.", f)
+				}
+
+				fmt.Sscanf(f[len(sym):], "%d", &exp)
+				if units = u.lookup(sym); len(units) == 0 {
+					return nil, fmt.Errorf("unknown unit: %v. 'dig unit' to see list of units.", sym)
+				}
+			}
+
+			// Offset and inverse units can't be multiplied or divided. The
+			// units are cloned as they're shared with the symbol maps.
+			units = slices.DeleteFunc(slices.Clone(units), func(un unit) bool { return un.Kind != kindLinear })
+			if len(units) == 0 {
+				return nil, fmt.Errorf("%s can't be used in a compound unit.", f)
+			}
+
+			// Every interpretation of the expression so far with every
+			// interpretation of the unit.
+			next := make([]quantity, 0, len(out)*len(units))
+			for _, q := range out {
+				for _, un := range units {
+					if len(next) < maxExpressions {
+						next = append(next, q.mul(un, sign*exp))
+					}
+				}
+			}
+			out = next
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,260 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxResults is the max number of units returned in a listing or a
+	// name search.
+	maxResults = 8
+
+	// maxSize is the max size of a response in bytes. The server only
+	// responds over UDP without compression or truncation, so responses
+	// have to fit in 512 byte messages.
+	maxSize = 512
+)
+
+// list returns the units that a unit expression can be converted to and
+// the value of one unit in them, eg: km = 0.621371 mi, as many as fit in a
+// response, followed by the number of units left out.
+func (u *Units) list(expr string) ([]string, error) {
+	from, err := u.parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ambiguous symbols (eg: oz) share the results between their groups.
+	var quantities []quantity
+	for _, f := range from {
+		if _, ok := u.groups[f.Dims]; ok {
+			quantities = append(quantities, f)
+		}
+	}
+
+	// Ambiguous symbols take turns so that every group is listed.
+	var (
+		lists  = make([][][]string, len(quantities))
+		groups = make([]string, 0, len(quantities))
+		total  = 0
+	)
+	for i, f := range quantities {
+		g := u.groups[f.Dims]
+		groups = append(groups, groupKey(g)+".unit")
+		for _, un := range u.sorted(g) {
+			if un.Symbol == f.Symbol {
+				continue
+			}
+
+			var (
+				t    = newQuantity(un)
+				b, _ = f.toBase(1)
+			)
+			lists[i] = append(lists[i], []string{g, fmt.Sprintf("1 %s = %s %s",
+				f.Symbol, formatNum(t.fromBase(b), minSigFigs), t.label())})
+			total++
+		}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("no units to convert %s (%s) to.", expr, u.dimName(from[0].Dims))
+	}
+
+	recs := make([][]string, 0, total)
+	for n := 0; len(recs) < total; n++ {
+		for _, l := range lists {
+			if n < len(l) {
+				recs = append(recs, l[n])
+			}
+		}
+	}
+
+	return fit(expr, recs, func(n int) string {
+		return fmt.Sprintf("%d more, dig %s for all the units", n, strings.Join(groups, " or "))
+	}), nil
+}
+
+// best returns a value in the unit that's the most readable, that is, the
+// largest unit in the same group and system of units that the value is at
+// least one of, eg: 123456789m = 123,457 km. Offset and inverse units are
+// left as is.
+func (u *Units) best(q, num, expr string) ([]string, error) {
+	val, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return nil, errors.New("invalid number.")
+	}
+
+	from, err := u.parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ambiguous symbols (eg: oz) resolve to the first interpretation with
+	// a group.
+	f := from[0]
+	for _, o := range from {
+		if _, ok := u.groups[o.Dims]; ok {
+			f = o
+			break
+		}
+	}
+
+	base, err := f.toBase(val)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		t     = f
+		found bool
+	)
+	if f.Kind == kindLinear {
+		for _, un := range u.sorted(u.groups[f.Dims]) {
+			if un.Kind != kindLinear || un.System != f.System {
+				continue
+			}
+
+			// The smallest unit is the fallback for values less than one
+			// of any unit.
+			c := newQuantity(un)
+			if !found || math.Abs(c.fromBase(base)) >= 1 {
+				t, found = c, true
+			}
+		}
+	}
+
+	conv := t.fromBase(base)
+	if math.IsInf(conv, 0) || math.IsNaN(conv) {
+		return nil, errors.New("invalid number.")
+	}
+
+	r := fmt.Sprintf("%s %d TXT \"%s %s = %s %s\"",
+		q, TTL, num, f.label(), groupDigits(formatNum(conv, minSigFigs)), t.label())
+
+	return []string{r}, nil
+}
+
+// search returns the units whose symbols or names match a pattern where ?
+// matches any characters, eg: kilo?, ?meter.
+func (u *Units) search(q string) ([]string, error) {
+	re, err := regexp.Compile("(?i)^" + strings.ReplaceAll(regexp.QuoteMeta(q), `\?`, ".*") + "$")
+	if err != nil {
+		return nil, errors.New("invalid search query.")
+	}
+
+	groups := make([]string, 0, len(u.units))
+	for g := range u.units {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+
+	var recs [][]string
+	for _, g := range groups {
+		for _, un := range u.sorted(g) {
+			if re.MatchString(un.Symbol) || re.MatchString(un.Name) {
+				recs = append(recs, []string{g, fmt.Sprintf("%s (%s)", un.Symbol, un.Name)})
+			}
+		}
+	}
+
+	if len(recs) == 0 {
+		return nil, fmt.Errorf("no units matching %s.", q)
+	}
+
+	return fit(q, recs, func(n int) string {
+		return fmt.Sprintf("%d more, narrow the search for the rest", n)
+	}), nil
+}
+
+// fit returns up to maxResults TXT records for a query with the given
+// strings, as many as fit in a response. If some are left out, the last
+// record is more(the number left out).
+func fit(q string, recs [][]string, more func(n int) string) []string {
+	var (
+		out     = make([]string, 0, min(len(recs), maxResults)+1)
+		size    = msgSize(q)
+		reserve = recordSize(q, more(len(recs)))
+	)
+	for i, r := range recs {
+		n := recordSize(q, r...)
+		if i < len(recs)-1 {
+			n += reserve
+		}
+		if len(out) == maxResults || size+n > maxSize {
+			break
+		}
+
+		size += recordSize(q, r...)
+		out = append(out, fmt.Sprintf("%s %d TXT \"%s\"", q, TTL, strings.Join(r, `" "`)))
+	}
+
+	if n := len(recs) - len(out); n > 0 {
+		out = append(out, fmt.Sprintf("%s %d TXT \"%s\"", q, TTL, more(n)))
+	}
+
+	return out
+}
+
+// msgSize returns the size of a response message for a query without
+// any records, that is, the header and the question.
+func msgSize(q string) int {
+	// The question is $q.unit. followed by its type and class.
+	return 12 + len(q) + len(".unit.") + 1 + 4
+}
+
+// recordSize returns the uncompressed size of a TXT record for a query
+// with the given strings.
+func recordSize(q string, txt ...string) int {
+	// The name is $q. followed by the type, class, TTL, and data length.
+	n := len(q) + 2 + 10
+	for _, s := range txt {
+		n += 1 + len(s)
+	}
+
+	return n
+}
+
+// sorted returns the units in a group sorted by their factors.
+func (u *Units) sorted(group string) []unit {
+	out := make([]unit, 0, len(u.units[group]))
+	for _, un := range u.units[group] {
+		out = append(out, un)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Factor != out[j].Factor {
+			return out[i].Factor < out[j].Factor
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+
+	return out
+}
+
+// groupDigits separates the thousands in the integer part of a number with
+// commas, eg: 123457 = 123,457. Numbers with exponents are left as is.
+func groupDigits(s string) string {
+	if strings.Contains(s, "e") {
+		return s
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	n, frac, _ := strings.Cut(s, ".")
+	for i := len(n) - 3; i > 0; i -= 3 {
+		n = n[:i] + "," + n[i:]
+	}
+	if frac != "" {
+		n += "." + frac
+	}
+
+	return sign + n
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,46 +16,89 @@ import (
 type fileData struct {
 	BaseSymbol string `json:"base_symbol"`
 	BaseName   string `json:"base_name"`
-	Units      []unit `json:"units"`
-}
 
-type group struct {
-	Name       string `json:"name"`
-	BaseSymbol string `json:"base_symbol"`
+	// Exponents of the base units, eg: {"m": 1, "s": -1} for speed.
+	Dimension map[string]int `json:"dimension"`
+	Units     []unit         `json:"units"`
 }
 
+// Kinds of conversions from a unit to the base units of its dimension.
+const (
+	// base = value * factor.
+	kindLinear = ""
+
+	// base = (value + offset) * factor, eg: temperatures.
+	kindOffset = "offset"
+
+	// base = factor / value, eg: L/100km, min/km.
+	kindInverse = "inverse"
+)
+
 type unit struct {
-	Symbol string  `json:"symbol"`
-	Name   string  `json:"name"`
-	Value  float64 `json:"value"`
+	Symbol string `json:"symbol"`
+	Name   string `json:"name"`
+
+	// Value of one unit in the SI base units of its dimension.
+	Factor float64 `json:"factor"`
+
+	// Optional. Kind of conversion and the offset of offset units.
+	Kind   string  `json:"kind"`
+	Offset float64 `json:"offset"`
+
+	// Whether SI prefixes can be applied to the unit, eg: kJ, MWh.
+	Prefix bool `json:"prefix"`
+
+	// Optional. System of units that best unit suggestions stay within,
+	// eg: imperial. Empty is SI and common metric units.
+	System string `json:"system"`
+
+	group string
+	dims  dims
 }
 
 // Units does conversions for physical units.
 type Units struct {
-	// List of valid conversions by category.
-	// category: {from, to: true} .
+	// Units by group.
+	// group: {symbol: unit} .
 	units map[string]map[string]unit
 
-	// symbol -> category map.
-	symbols map[string]group
+	// symbol -> units map. A symbol can be in multiple groups, eg: oz.
+	symbols map[string][]unit
+
+	// lowercase symbol -> units map for case-insensitive lookups.
+	folded map[string][]unit
+
+	// dimensions -> group name map.
+	groups map[dims]string
 
 	// Units list helptext.
 	help []string
 }
 
-// TTL is set to 900 seconds (15 minutes).
-const TTL = 900
+const (
+	// TTL is set to 900 seconds (15 minutes).
+	TTL = 900
+
+	// minSigFigs is the min number of significant figures in results.
+	minSigFigs = 6
+)
 
 //go:embed units.json
 var dataB []byte
 
-var reParse = regexp.MustCompile(`(?i)([0-9\.]+)([a-z]{1,6})\-([a-z]{1,6})`)
+var (
+	reParse = regexp.MustCompile(`(?i)^(\-?[0-9\.]+)([a-z0-9/\.]+)\-([a-z0-9/\.]+)$`)
+	reBest  = regexp.MustCompile(`(?i)^(\-?[0-9\.]+)([a-z0-9/\.]+)\.best$`)
+	reUnit  = regexp.MustCompile(`(?i)^[a-z][a-z0-9/\.]*$`)
+)
 
 // New returns a new instance of Units.
 func New() (*Units, error) {
 	u := &Units{
 		units:   make(map[string]map[string]unit),
-		symbols: make(map[string]group),
+		symbols: make(map[string][]unit),
+		folded:  make(map[string][]unit),
+		groups:  make(map[dims]string),
 	}
 
 	if err := u.load(dataB); err != nil {
@@ -70,70 +115,73 @@ func (u *Units) Query(q string) ([]string, error) {
 		return u.help, nil
 	}
 
+	// Lookup by name, eg: kilo?
+	if strings.Contains(q, "?") {
+		return u.search(q)
+	}
+
+	// Value in the best unit, eg: 123456789m.best
+	if res := reBest.FindStringSubmatch(q); len(res) == 3 {
+		return u.best(q, res[1], res[2])
+	}
+
 	res := reParse.FindStringSubmatch(q)
 	if len(res) != 4 {
+		// Units in a group, eg: length
+		if out, ok := u.printGroup(q); ok {
+			return out, nil
+		}
+
+		// Units convertible from a unit, eg: km
+		if reUnit.MatchString(q) {
+			return u.list(q)
+		}
+
 		return nil, errors.New("invalid unit query.")
 	}
 
 	// Parse the numeric value.
-	val, err := strconv.ParseFloat(res[1], 32)
+	val, err := strconv.ParseFloat(res[1], 64)
 	if err != nil {
 		return nil, errors.New("invalid number.")
 	}
 
-	var (
-		fromSym = res[2]
-		toSym   = res[3]
-	)
-
-	// Validate unit symbols.
-	g, ok := u.symbols[fromSym]
-	if !ok {
-		// Try lowercase.
-		f := strings.ToLower(fromSym)
-		lg, ok := u.symbols[f]
-		if !ok {
-			return nil, fmt.Errorf("unknown unit: %v. 'dig unit' to see list of units.", fromSym)
-		}
-
-		g = lg
-		fromSym = f
+	from, err := u.parse(res[2])
+	if err != nil {
+		return nil, err
 	}
-	from := u.units[g.Name][fromSym]
-
-	// Get the to unit irrespective of the group so that its name
-	// can be used in case of an error.
-	toG, ok := u.symbols[toSym]
-	if !ok {
-		// Try lowercase.
-		f := strings.ToLower(toSym)
-		lg, ok := u.symbols[f]
-		if !ok {
-			return nil, fmt.Errorf("unknown unit: %v. 'dig unit' to see list of units.", toSym)
-		}
-
-		toG = lg
-		toSym = f
+	to, err := u.parse(res[3])
+	if err != nil {
+		return nil, err
 	}
-	toReal := u.units[toG.Name][toSym]
 
-	// The from->to conversion is only valid if the to symbol belongs to the same
-	// group as the form symbol.
-	to, ok := u.units[g.Name][toSym]
-	if !ok {
-		return nil, fmt.Errorf("cannot convert %s (%s) to %s (%s).",
-			fromSym, from.Name, toSym, toReal.Name)
-	}
+	// The from->to conversion is only valid if both have the same dimensions.
+	// Ambiguous symbols (eg: oz) resolve to the first matching interpretation.
+	for _, f := range from {
+		for _, t := range to {
+			if f.Dims != t.Dims {
+				continue
+			}
 
-	baseRate := u.units[g.Name][g.BaseSymbol].Value
+			// Convert.
+			b, err := f.toBase(val)
+			if err != nil {
+				return nil, err
+			}
+			conv := t.fromBase(b)
+			if math.IsInf(conv, 0) || math.IsNaN(conv) {
+				return nil, errors.New("invalid number.")
+			}
 
-	// Convert.
-	conv := (baseRate / from.Value) / (baseRate / to.Value) * val
+			r := fmt.Sprintf("%s %d TXT \"%s %s = %s %s\"",
+				q, TTL, res[1], f.label(), formatNum(conv, max(sigFigs(res[1]), minSigFigs)), t.label())
 
-	r := fmt.Sprintf("%s %d TXT \"%0.2f %s (%s) = %0.2f %s (%s)\"",
-		q, TTL, val, from.Name, from.Symbol, conv, to.Name, to.Symbol)
+			return []string{r}, nil
+		}
+	}
 
-	return []string{r}, nil
+	return nil, fmt.Errorf("cannot convert %s (%s) to %s (%s).",
+		from[0].label(), u.dimName(from[0].Dims), to[0].label(), u.dimName(to[0].Dims))
 }
 
 // Dump is not implemented in this package.
@@ -141,41 +189,43 @@ func (u *Units) Dump() ([]byte, error) {
 	return nil, nil
 }
 
+// printUnitsList returns the list of unit groups. The full list of units
+// doesn't fit in a UDP response and is listed per group.
 func (u *Units) printUnitsList() []string {
-	var (
-		out    = make([]string, 0, len(u.symbols))
-		groups = make([]string, 0, len(u.units))
-	)
-
-	// Collect the group names and sort.
+	groups := make([]string, 0, len(u.units))
 	for g := range u.units {
-		groups = append(groups, g)
+		groups = append(groups, groupKey(g))
 	}
-	sort.Slice(groups, func(i, j int) bool {
-		return groups[i] < groups[j]
-	})
+	sort.Strings(groups)
 
-	for _, g := range groups {
-		units := u.units[g]
+	return []string{
+		fmt.Sprintf("unit. %d TXT \"%s\" \"dig <group>.unit to list its units, eg: length.unit\"",
+			TTL, strings.Join(groups, " ")),
+	}
+}
 
-		// Collect the units in the group in a list.
-		list := make([]unit, 0, len(units))
-		for _, un := range units {
-			list = append(list, un)
+// printGroup returns the symbols of the units in a group, eg: length.
+func (u *Units) printGroup(q string) ([]string, bool) {
+	for g := range u.units {
+		if groupKey(g) != strings.ToLower(q) {
+			continue
 		}
 
-		// Sort the list.
-		sort.Slice(list, func(i, j int) bool {
-			return list[i].Symbol < list[j].Symbol
-		})
-
-		for _, un := range list {
-			l := fmt.Sprintf("unit. %d \"%s\" \"%s (%s)\"", TTL, g, un.Symbol, un.Name)
-			out = append(out, l)
+		units := u.sorted(g)
+		syms := make([]string, 0, len(units))
+		for _, un := range units {
+			syms = append(syms, un.Symbol)
 		}
+
+		return []string{fmt.Sprintf("%s %d TXT \"%s\" \"%s\"", q, TTL, g, strings.Join(syms, " "))}, true
 	}
 
-	return out
+	return nil, false
+}
+
+// groupKey returns the name of a group as it's queried, eg: fuel-economy.
+func groupKey(g string) string {
+	return strings.ReplaceAll(g, " ", "-")
 }
 
 func (u *Units) load(b []byte) error {
@@ -185,17 +235,86 @@ func (u *Units) load(b []byte) error {
 		return err
 	}
 
-	// Prepare the list of valid from-to conversions.
-	for groupName, g := range data {
+	// Prepare the list of valid from-to conversions. Groups are loaded in
+	// order so that the order of ambiguous symbols is stable.
+	names := make([]string, 0, len(data))
+	for groupName := range data {
+		names = append(names, groupName)
+	}
+	sort.Strings(names)
+
+	for _, groupName := range names {
+		g := data[groupName]
+		var d dims
+		for sym, e := range g.Dimension {
+			n := slices.Index(dimSymbols[:], sym)
+			if n < 0 {
+				return fmt.Errorf("unknown dimension %s in %s", sym, groupName)
+			}
+			d[n] = e
+		}
+		u.groups[d] = groupName
+
 		u.units[groupName] = map[string]unit{}
 		for _, un := range g.Units {
-			u.symbols[un.Symbol] = group{
-				Name:       groupName,
-				BaseSymbol: g.BaseSymbol,
+			switch un.Kind {
+			case kindLinear, kindOffset, kindInverse:
+			default:
+				return fmt.Errorf("unknown kind %s for %s in %s", un.Kind, un.Symbol, groupName)
 			}
+
+			un.group = groupName
+			un.dims = d
+
+			u.symbols[un.Symbol] = append(u.symbols[un.Symbol], un)
+			u.folded[strings.ToLower(un.Symbol)] = append(u.folded[strings.ToLower(un.Symbol)], un)
 			u.units[groupName][un.Symbol] = un
 		}
 	}
 
 	return nil
 }
+
+// sigFigs returns the number of significant figures in a number, eg: 0.0250 = 3.
+func sigFigs(s string) int {
+	s = strings.TrimLeft(strings.TrimPrefix(s, "-"), "0.")
+	if !strings.Contains(s, ".") {
+		// Trailing zeros in integers aren't significant.
+		s = strings.TrimRight(s, "0")
+	}
+
+	return len(strings.ReplaceAll(s, ".", ""))
+}
+
+// formatNum formats a number to the given significant figures without an
+// exponent and trailing zeros, eg: 26.0976, 0.000123457. Very large and
+// small numbers use the exponent notation, eg: 1.23457e+20.
+func formatNum(v float64, sig int) string {
+	if v == 0 {
+		return "0"
+	}
+
+	a := math.Abs(v)
+	if a >= 1e15 || a < 1e-6 {
+		return strconv.FormatFloat(v, 'g', sig, 64)
+	}
+
+	// Decimal places for the significant figures.
+	dec := max(sig-1-int(math.Floor(math.Log10(a))), 0)
+	s := strconv.FormatFloat(v, 'f', dec, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+
+	return s
+}
+
+// dimName returns the name of the group of the dimensions (eg: speed) or
+// the dimensions in base units if there's no group (eg: m.s).
+func (u *Units) dimName(d dims) string {
+	if g, ok := u.groups[d]; ok {
+		return g
+	}
+
+	return d.String()
+}
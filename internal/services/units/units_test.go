@@ -0,0 +1,211 @@
+package units
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestQuery(t *testing.T) {
+	u, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"42km-mi", `"42 Kilometer (km) = 26.0976 Mile (mi)"`},
+		{"42KM-MI", `"42 Kilometer (km) = 26.0976 Mile (mi)"`},
+		{"60mi/h-m/s", `"60 mi/h = 26.8224 Meter/sec (m/s)"`},
+		{"9.8m/s2-ft/s2", `"9.8 Meter/sec2 (m/s2) = 32.1522 ft/s2"`},
+		{"5kWh-MJ", `"5 Kilowatt-hour (kWh) = 18 Megajoule (MJ)"`},
+		{"2Mm-km", `"2 Megameter (Mm) = 2000 Kilometer (km)"`},
+		{"1atm-psi", `"1 Atmosphere (atm) = 14.6959 Pound/sq. inch (psi)"`},
+		{"10N.m-J", `"10 N.m = 10 Joule (J)"`},
+		{"1g/cm3-kg/m3", `"1 Gram/cubic centimeter (g/cm3) = 1000 Kilogram/cubic meter (kg/m3)"`},
+		{"30mpg-km/l", `"30 Mile/US gal (mpg) = 12.7543 Kilometer/liter (km/l)"`},
+		{"2cm3-ml", `"2 cm3 = 2 Milliliter (ml)"`},
+
+		// Offset and inverse units.
+		{"100C-F", `"100 Celsius (C) = 212 Fahrenheit (F)"`},
+		{"-40F-C", `"-40 Fahrenheit (F) = -40 Celsius (C)"`},
+		{"0K-C", `"0 Kelvin (K) = -273.15 Celsius (C)"`},
+		{"-273.15C-K", `"-273.15 Celsius (C) = 0 Kelvin (K)"`},
+		{"25mpg-l/100km", `"25 Mile/US gal (mpg) = 9.40858 Liter/100 kilometers (l/100km)"`},
+		{"5min/km-km/h", `"5 Minute/kilometer (pace) (min/km) = 12 km/h"`},
+
+		// Case-insensitive symbols as DNS names are case-insensitive.
+		{"100c-f", `"100 Celsius (C) = 212 Fahrenheit (F)"`},
+		{"-40c-f", `"-40 Celsius (C) = -40 Fahrenheit (F)"`},
+		{"0c-k", `"0 Celsius (C) = 273.15 Kelvin (K)"`},
+		{"1pa-psi", `"1 Pascal (Pa) = 0.000145038 Pound/sq. inch (psi)"`},
+		{"1mwh-j", `"1 Megawatt-hour (MWh) = 3600000000 Joule (J)"`},
+		{"5kwh-mj", `"5 Kilowatt-hour (kWh) = 18 Megajoule (MJ)"`},
+		{"1gb-mb", `= 1000 Megabit (Mb)"`},
+		{"5KWH-MJ", `"5 Kilowatt-hour (kWh) = 18 Megajoule (MJ)"`},
+
+		// Significant figures of the input.
+		{"1.23456789m-ft", `"1.23456789 Meter (m) = 4.05041959 Foot (ft)"`},
+		{"1ly-nm", `"1 Light year (ly) = 9.46073e+24 Nanometer (nm)"`},
+
+		// oz is both mass and volume.
+		{"16oz-lb", `"16 Ounce (oz) = 1 Pound (lb)"`},
+		{"10oz-ml", `"10 US oz (oz) = 295.735 Milliliter (ml)"`},
+	}
+	for _, tc := range tests {
+		out, err := u.Query(tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.q, err)
+			continue
+		}
+		if !strings.HasSuffix(out[0], tc.want) {
+			t.Errorf("%s: want %s got %s", tc.q, tc.want, out[0])
+		}
+	}
+
+	for _, q := range []string{"1kg-m", "1m/s-J", "1xyz-m", "1m-s2x", "km-mi", "1C.m-J", "0mpg-l/100km"} {
+		if _, err := u.Query(q); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+
+	// Temperatures below absolute zero.
+	for _, q := range []string{"-300C-K", "-500F-C", "-1K-C", "-5R-K", "-300C.best"} {
+		if _, err := u.Query(q); err == nil || err.Error() != "temperature is below absolute zero." {
+			t.Errorf("%s: expected absolute zero error, got %v", q, err)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	u, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := u.Query("km")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(out, `km 900 TXT "length" "1 km = 0.621371 Mile (mi)"`) {
+		t.Errorf("km not converted to mi: %v", out)
+	}
+
+	// oz is both mass and volume.
+	out, err = u.Query("oz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(out, `oz 900 TXT "mass" "1 oz = 0.0625 Pound (lb)"`) ||
+		!slices.Contains(out, `oz 900 TXT "volume" "1 oz = 29.5735 Milliliter (ml)"`) {
+		t.Errorf("oz not converted to mass and volume: %v", out)
+	}
+
+	if _, err := u.Query("kg.m"); err == nil {
+		t.Error("kg.m: expected error")
+	}
+}
+
+func TestBest(t *testing.T) {
+	u, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"123456789m.best", `"123456789 Meter (m) = 123,457 Kilometer (km)"`},
+		{"0.5m.best", `"0.5 Meter (m) = 50 Centimeter (cm)"`},
+		{"-2500m.best", `"-2500 Meter (m) = -2.5 Kilometer (km)"`},
+		{"123456789ft.best", `"123456789 Foot (ft) = 23,382 Mile (mi)"`},
+		{"100000s.best", `"100000 Second (s) = 1.15741 Day (d)"`},
+		{"123456789b.best", `"123456789 Bit (b) = 123.457 Megabit (Mb)"`},
+		{"300C.best", `"300 Celsius (C) = 300 Celsius (C)"`},
+	}
+	for _, tc := range tests {
+		out, err := u.Query(tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.q, err)
+			continue
+		}
+		if !strings.HasSuffix(out[0], tc.want) {
+			t.Errorf("%s: want %s got %s", tc.q, tc.want, out[0])
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	u, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := u.Query("?gal?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 4 || !slices.Contains(out, `?gal? 900 TXT "volume" "igal (Imperial gal)"`) {
+		t.Errorf("unexpected results: %v", out)
+	}
+
+	// Results that don't fit are counted in the last record.
+	if out, _ := u.Query("?"); len(out) != maxResults+1 || !strings.Contains(out[maxResults], "more, narrow the search") {
+		t.Errorf("want %d results and more got %v", maxResults, out)
+	}
+	if _, err := u.Query("zzz?"); err == nil {
+		t.Error("zzz?: expected error")
+	}
+}
+
+func TestResponseSize(t *testing.T) {
+	u, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Listings should fit in a 512 byte UDP message without compression.
+	for _, q := range []string{"unit.", "km", "oz", "?", "?meter?", "length", "volume", "fuel-economy"} {
+		out, err := u.Query(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+
+		m := &dns.Msg{}
+		m.SetQuestion(dns.Fqdn(q+".unit"), dns.TypeTXT)
+		for _, r := range out {
+			rr, err := dns.NewRR(r)
+			if err != nil {
+				t.Fatalf("%s: invalid RR: %v", r, err)
+			}
+			m.Answer = append(m.Answer, rr)
+		}
+		if n := m.Len(); n > 512 {
+			t.Errorf("%s: response is %d bytes", q, n)
+		}
+	}
+
+	// Listings that are cut off say how many units are left out, and
+	// ambiguous symbols list every group.
+	out, _ := u.Query("km")
+	if len(out) != maxResults+1 || out[maxResults] != `km 900 TXT "2 more, dig length.unit for all the units"` {
+		t.Errorf("unexpected listing: %v", out)
+	}
+	out, _ = u.Query("oz")
+	if !strings.Contains(strings.Join(out, "\n"), `"mass"`) || !strings.Contains(out[len(out)-1], "dig mass.unit or volume.unit") {
+		t.Errorf("unexpected ambiguous listing: %v", out)
+	}
+	if out, _ := u.Query("J"); len(out) != 4 || strings.Contains(strings.Join(out, "\n"), "more") {
+		t.Errorf("unexpected complete listing: %v", out)
+	}
+
+	if out, _ := u.Query("fuel-economy"); len(out) != 1 || !strings.Contains(out[0], "l/100km") {
+		t.Errorf("unexpected group listing: %v", out)
+	}
+}
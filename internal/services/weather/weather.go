@@ -134,7 +134,7 @@ func New(o Opt, g *geo.Geo) *Weather {
 func (w *Weather) Query(q string) ([]string, error) {
 	locs := w.geo.Query(q)
 	if locs == nil {
-		return nil, errors.New("unknown city.")
+		return nil, w.geo.NotFound(q)
 	}
 
 	out := make([]string, 0, len(locs)*3)
@@ -158,7 +158,7 @@ func (w *Weather) Query(q string) ([]string, error) {
 
 		for _, f := range data.Forecasts {
 			r := fmt.Sprintf("%s %d TXT \"%s (%s)\" \"%0.2fC (%0.2fF)\" \"%0.2f%% hu.\" \"%s\" \"%s\"",
-				q, TTL, l.Name, l.Country, f.TempC, f.TempF, f.Humidity, f.Forecast1H, f.Time.In(zone).Format("15:04, Mon"))
+				q, TTL, l.Name, l.Place(), f.TempC, f.TempF, f.Humidity, f.Forecast1H, f.Time.In(zone).Format("15:04, Mon"))
 			out = append(out, r)
 		}
 